@@ -8,14 +8,18 @@
 package climate
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"strconv"
 	"strings"
 
 	"github.com/pb33f/libopenapi"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 type HandlerCobra func(opts *cobra.Command, args []string, data HandlerData) error
@@ -23,55 +27,66 @@ type HandlerCobra func(opts *cobra.Command, args []string, data HandlerData) err
 // Deprecated: Use HandlerCobra instead
 type Handler = HandlerCobra
 
-func addParams(cmd *cobra.Command, op *v3.Operation, handlerData *HandlerData) {
-	var (
-		queryParams  []ParamMeta
-		pathParams   []ParamMeta
-		headerParams []ParamMeta
-		cookieParams []ParamMeta
-	)
+// A handler getting the context of the command, cancelled on Ctrl-C or when the --timeout passes
+type HandlerCobraCtx func(ctx context.Context, opts *cobra.Command, args []string, data HandlerData) error
+
+// Adapts the handler to a HandlerCobraCtx, the context is still available as opts.Context()
+func (h HandlerCobra) Ctx() HandlerCobraCtx {
+	return func(_ context.Context, opts *cobra.Command, args []string, data HandlerData) error {
+		return h(opts, args, data)
+	}
+}
+
+// Cobra has no env var support, the flags bound to one are annotated with it and set before running
+const envAnnotation = "climate_env"
+
+func bindEnvCobra(flags *pflag.FlagSet, name, env string) {
+	flags.SetAnnotation(name, envAnnotation, []string{env})
+}
+
+func addParams(cmd *cobra.Command, op *v3.Operation, handlerData *HandlerData, rootName string) error {
 	flags := cmd.Flags()
 
 	for _, param := range op.Parameters {
 		t := getParamType(param, op)
+		env, err := paramEnvVar(rootName, param)
+		if err != nil {
+			return err
+		}
+		usage := strings.TrimSpace(fmt.Sprintf("%s [$%s]", param.Description, env))
 
 		switch t {
 		case String:
-			flags.String(param.Name, "", param.Description)
+			var value string
+			paramDefault(param, &value)
+			flags.String(param.Name, value, usage)
 		case Integer:
-			flags.Int(param.Name, 0, param.Description)
+			var value int
+			paramDefault(param, &value)
+			flags.Int(param.Name, value, usage)
 		case Number:
-			flags.Float64(param.Name, 0.0, param.Description)
+			var value float64
+			paramDefault(param, &value)
+			flags.Float64(param.Name, value, usage)
 		case Boolean:
-			flags.Bool(param.Name, false, param.Description)
+			var value bool
+			paramDefault(param, &value)
+			flags.Bool(param.Name, value, usage)
 		default:
 			// TODO: array, object
 			slog.Warn("TODO: Unhandled param", "name", param.Name, "type", param.Schema.Schema().Type[0])
 			continue
 		}
-
-		// TODO: Extract commom
-		meta := ParamMeta{Name: param.Name, Type: t}
-		switch param.In {
-		case "path":
-			pathParams = append(pathParams, meta)
-		case "query":
-			queryParams = append(queryParams, meta)
-		case "header":
-			headerParams = append(headerParams, meta)
-		case "cookie":
-			cookieParams = append(cookieParams, meta)
-		}
+		bindEnvCobra(flags, param.Name, env)
 
 		if req := param.Required; req != nil && *req {
 			cmd.MarkFlagRequired(param.Name)
 		}
 	}
 
-	handlerData.QueryParams = queryParams
-	handlerData.PathParams = pathParams
-	handlerData.HeaderParams = headerParams
-	handlerData.CookieParams = cookieParams
+	handlerData.PathParams, handlerData.QueryParams, handlerData.HeaderParams, handlerData.CookieParams = paramMetas(op)
+
+	return nil
 }
 
 func addRequestBodyCobra(cmd *cobra.Command, op *v3.Operation, handlerData *HandlerData) error {
@@ -89,6 +104,202 @@ func addRequestBodyCobra(cmd *cobra.Command, op *v3.Operation, handlerData *Hand
 	return nil
 }
 
+func addSkeletonCobra(cmd *cobra.Command) {
+	cmd.Flags().String(skeletonFlag, "", skeletonUsage)
+	cmd.Flags().Lookup(skeletonFlag).NoOptDefVal = defaultSkeletonFormat
+
+	// Required flags are validated after PreRunE, relax them to only print the skeleton
+	cmd.PreRunE = func(opts *cobra.Command, _ []string) error {
+		if opts.Flags().Changed(skeletonFlag) {
+			opts.Flags().VisitAll(func(flag *pflag.Flag) {
+				delete(flag.Annotations, cobra.BashCompOneRequiredFlag)
+			})
+		}
+
+		return nil
+	}
+}
+
+func addPaginationCobra(cmd *cobra.Command) {
+	cmd.Flags().Bool(allFlag, false, allUsage)
+	cmd.Flags().Int(maxItemsFlag, 0, maxItemsUsage)
+}
+
+func addAuthFlagsCobra(rootCmd *cobra.Command, model *v3.Document) {
+	flags := rootCmd.PersistentFlags()
+
+	for _, flag := range makeAuthFlags(model) {
+		if flags.Lookup(flag.name) == nil {
+			env := envVarName(rootCmd.Name(), flag.name)
+			flags.String(flag.name, "", fmt.Sprintf("%s [$%s]", flag.usage, env))
+			bindEnvCobra(flags, flag.name, env)
+		}
+	}
+}
+
+// Adds a root flag bound to an env var unless it's already defined
+func addRootFlagCobra(rootCmd *cobra.Command, name, value, usage string) {
+	flags := rootCmd.PersistentFlags()
+
+	if flags.Lookup(name) == nil {
+		env := envVarName(rootCmd.Name(), name)
+		flags.String(name, value, fmt.Sprintf("%s [$%s]", usage, env))
+		bindEnvCobra(flags, name, env)
+	}
+}
+
+// Adds the root --verbose flag counting how often it's passed, eg -vv, unless it's already defined
+func addVerboseFlagCobra(rootCmd *cobra.Command) {
+	flags := rootCmd.PersistentFlags()
+
+	switch {
+	case flags.Lookup(verboseFlag) != nil:
+	case flags.ShorthandLookup("v") != nil:
+		flags.Count(verboseFlag, verboseUsage)
+	default:
+		flags.CountP(verboseFlag, "v", verboseUsage)
+	}
+}
+
+// Before the required flags are validated, fills in the flags not set on the command line from their env vars.
+// Then selects the profile, filling in the ones still not set from it.
+func addFlagSourcesCobra(cmd *cobra.Command, o *options) {
+	preRunE := cmd.PreRunE
+
+	cmd.PreRunE = func(opts *cobra.Command, args []string) error {
+		flags := opts.Flags()
+
+		var err error
+		flags.VisitAll(func(flag *pflag.Flag) {
+			env, ok := flag.Annotations[envAnnotation]
+			if !ok || flag.Changed || err != nil {
+				return
+			}
+
+			if value := os.Getenv(env[0]); value != "" {
+				if e := flags.Set(flag.Name, value); e != nil {
+					err = fmt.Errorf("Invalid value for --%s in $%s: %w", flag.Name, env[0], e)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		profileName, _ := flags.GetString(profileFlag)
+		p, err := o.selectProfile(profileName)
+		if err != nil {
+			return err
+		}
+
+		for name, value := range p.Flags {
+			flag := flags.Lookup(name)
+			if flag == nil || flag.Changed {
+				continue
+			}
+
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("Invalid value for --%s in profile %s: %w", name, p.name, err)
+			}
+		}
+
+		opts.SetContext(withProfile(opts.Context(), p))
+
+		if preRunE != nil {
+			return preRunE(opts, args)
+		}
+
+		return nil
+	}
+}
+
+// Adds login when a scheme supports it, logout and auth status when there are any schemes
+func addAuthCommandsCobra(rootCmd *cobra.Command, model *v3.Document, o *options) {
+	if len(authSchemes(model)) == 0 {
+		return
+	}
+
+	rootName := rootCmd.Name()
+	resolver := func(cmd *cobra.Command) credentialResolver {
+		return newCredentialResolver(model, rootName, o, profileFrom(cmd.Context()), lookupFlagCobra(cmd, rootName))
+	}
+
+	// Runs like the operations: cancelled on Ctrl-C or when the --timeout passes, failing with their exit codes
+	run := func(run func(ctx context.Context, opts *cobra.Command) error) func(*cobra.Command, []string) error {
+		return func(opts *cobra.Command, _ []string) error {
+			timeout, _ := opts.Flags().GetString(timeoutFlag)
+			ctx, cancel, err := commandContext(opts.Context(), timeout)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+			opts.SetContext(ctx)
+
+			return o.exitCodes.wrap(commandError(ctx, run(ctx, opts)))
+		}
+	}
+
+	if len(loginSchemes(model)) > 0 {
+		login := &cobra.Command{
+			Use:   loginCmd,
+			Short: loginUsage,
+			RunE: run(func(ctx context.Context, opts *cobra.Command) error {
+				scheme, _ := opts.Flags().GetString(schemeFlag)
+				noBrowser, _ := opts.Flags().GetBool(noBrowserFlag)
+				device, _ := opts.Flags().GetBool(deviceFlag)
+
+				return resolver(opts).login(ctx, opts.OutOrStdout(), scheme, !noBrowser, device)
+			}),
+		}
+		login.Flags().String(schemeFlag, "", schemeUsage)
+		login.Flags().Bool(noBrowserFlag, false, noBrowserUsage)
+		login.Flags().Bool(deviceFlag, false, deviceUsage)
+		addFlagSourcesCobra(login, o)
+
+		rootCmd.AddCommand(login)
+	}
+
+	logout := &cobra.Command{
+		Use:   logoutCmd,
+		Short: logoutUsage,
+		RunE: run(func(_ context.Context, opts *cobra.Command) error {
+			scheme, _ := opts.Flags().GetString(schemeFlag)
+
+			return resolver(opts).logout(opts.OutOrStdout(), scheme)
+		}),
+	}
+	logout.Flags().String(schemeFlag, "", logoutSchemeUsage)
+	addFlagSourcesCobra(logout, o)
+
+	status := &cobra.Command{
+		Use:   statusCmd,
+		Short: statusUsage,
+		RunE: run(func(_ context.Context, opts *cobra.Command) error {
+			return resolver(opts).status(opts.OutOrStdout())
+		}),
+	}
+	addFlagSourcesCobra(status, o)
+
+	auth := &cobra.Command{
+		Use:   authCmd,
+		Short: authUsage,
+	}
+	auth.AddCommand(status)
+
+	rootCmd.AddCommand(logout, auth)
+}
+
+// Looks up a flag by name, falling back to its env var when not set on the command line
+func lookupFlagCobra(cmd *cobra.Command, rootName string) func(string) string {
+	return func(name string) string {
+		if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+			return flag.Value.String()
+		}
+
+		return os.Getenv(envVarName(rootName, name))
+	}
+}
+
 func interpolatePathCobra(cmd *cobra.Command, h *HandlerData) error {
 	// TODO: Extract commom
 	flags := cmd.Flags()
@@ -116,9 +327,182 @@ func interpolatePathCobra(cmd *cobra.Command, h *HandlerData) error {
 	return nil
 }
 
+// Adds a wait subcommand per waiter, with the flags of the params of the operation it polls
+func addWaitersCobra(rootCmd *cobra.Command, model *v3.Document, waiters []*Waiter, o *options) error {
+	if len(waiters) == 0 {
+		return nil
+	}
+
+	waitGroup := &cobra.Command{Use: waitCmd, Short: waitUsage}
+	for _, waiter := range waiters {
+		cmd := &cobra.Command{
+			Use:   waiter.Name,
+			Short: fmt.Sprintf("Wait until %s of %s is one of: %s", waiter.Path, waiter.Operation, strings.Join(waiter.Success, ", ")),
+		}
+
+		hData := HandlerData{
+			Method:    waiter.method,
+			Path:      waiter.path,
+			Columns:   waiter.columns,
+			responses: waiter.target.Responses,
+			client:    o.httpClient,
+		}
+		if err := addParams(cmd, waiter.target, &hData, rootCmd.Name()); err != nil {
+			return err
+		}
+		addFlagSourcesCobra(cmd, o)
+
+		cmd.RunE = func(opts *cobra.Command, _ []string) error {
+			timeout, _ := opts.Flags().GetString(timeoutFlag)
+			ctx, cancel, err := commandContext(opts.Context(), timeout)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+			opts.SetContext(ctx)
+
+			data := hData
+			if _, err := prepareCobra(opts, model, waiter.target, o, rootCmd.Name(), &data); err != nil {
+				return commandError(ctx, err)
+			}
+			defer data.archive.flush()
+
+			return o.exitCodes.wrap(commandError(ctx, waiter.wait(ctx, opts.OutOrStdout(), data)))
+		}
+
+		waitGroup.AddCommand(cmd)
+	}
+
+	rootCmd.AddCommand(waitGroup)
+
+	return nil
+}
+
+// Fills in the data of an operation being run from the flags, the profile and the credentials it needs.
+// The resolver is returned to resolve the credentials of other operations.
+func prepareCobra(
+	opts *cobra.Command,
+	model *v3.Document,
+	op *v3.Operation,
+	o *options,
+	rootName string,
+	hData *HandlerData,
+) (credentialResolver, error) {
+	var err error
+	hData.param = paramLookupCobra(opts)
+
+	if err := interpolatePathCobra(opts, hData); err != nil {
+		return credentialResolver{}, err
+	}
+
+	output, _ := opts.Flags().GetString(outputFlag)
+	if hData.Output, err = parseOutputFormat(output); err != nil {
+		return credentialResolver{}, err
+	}
+	hData.outputSet = opts.Flags().Changed(outputFlag)
+
+	// Fail on a bad query before any request is made
+	if hData.Query, _ = opts.Flags().GetString(queryFlag); hData.Query != "" {
+		if _, err := parseQuery(hData.Query); err != nil {
+			return credentialResolver{}, err
+		}
+	}
+
+	validation, _ := opts.Flags().GetString(validateFlag)
+	if hData.Validation, err = parseValidationMode(validation); err != nil {
+		return credentialResolver{}, err
+	}
+
+	if hData.Pagination != nil {
+		hData.AllPages, _ = opts.Flags().GetBool(allFlag)
+		hData.MaxItems, _ = opts.Flags().GetInt(maxItemsFlag)
+	}
+
+	if hData.LongRunning != nil {
+		hData.NoWait, _ = opts.Flags().GetBool(noWaitFlag)
+	}
+
+	if hData.Binary {
+		hData.OutputFile, _ = opts.Flags().GetString(outputFileFlag)
+		hData.Force, _ = opts.Flags().GetBool(forceFlag)
+	}
+
+	hData.DryRun, _ = opts.Flags().GetBool(dryRunFlag)
+	hData.Curl, _ = opts.Flags().GetBool(curlFlag)
+	hData.Verbose, _ = opts.Flags().GetCount(verboseFlag)
+	hData.out, hData.errOut = opts.OutOrStdout(), opts.ErrOrStderr()
+	hData.secrets = secretsOf(model, op)
+
+	record, _ := opts.Flags().GetString(recordFlag)
+	replay, _ := opts.Flags().GetString(replayFlag)
+	if hData.archive, err = openArchive(record, replay, rootName); err != nil {
+		return credentialResolver{}, err
+	}
+
+	p := profileFrom(opts.Context())
+	hData.Server = p.serverURL(model)
+
+	resolver := newCredentialResolver(model, rootName, o, p, lookupFlagCobra(opts, rootName))
+	resolver.replay = hData.archive != nil && hData.archive.replay
+	creds, err := resolver.resolve(opts.Context(), op)
+	if err != nil {
+		return credentialResolver{}, err
+	}
+	hData.Credentials = creds
+
+	return resolver, nil
+}
+
+// Looks up the value of the flag of a param, whether it was set or has a default
+func paramLookupCobra(cmd *cobra.Command) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			return "", false
+		}
+
+		value := flag.Value.String()
+
+		return value, flag.Changed || !isZeroFlag(value)
+	}
+}
+
 // Bootstraps a cobra.Command with the loaded model and a handler map
-func BootstrapV3Cobra(rootCmd *cobra.Command, model libopenapi.DocumentModel[v3.Document], handlers map[string]HandlerCobra) error {
+func BootstrapV3Cobra(
+	rootCmd *cobra.Command,
+	model libopenapi.DocumentModel[v3.Document],
+	handlers map[string]HandlerCobra,
+	opts ...Option,
+) error {
+	ctxHandlers := make(map[string]HandlerCobraCtx, len(handlers))
+	for id, handler := range handlers {
+		ctxHandlers[id] = handler.Ctx()
+	}
+
+	return BootstrapV3CobraCtx(rootCmd, model, ctxHandlers, opts...)
+}
+
+// Bootstraps a cobra.Command with the loaded model and a map of handlers getting the context of the command
+func BootstrapV3CobraCtx(
+	rootCmd *cobra.Command,
+	model libopenapi.DocumentModel[v3.Document],
+	handlers map[string]HandlerCobraCtx,
+	opts ...Option,
+) error {
 	cmdGroups := make(map[string][]cobra.Command)
+	var waiters []*Waiter
+	rootName := rootCmd.Name()
+	o := makeOptions(rootName, opts)
+	addRootFlagCobra(rootCmd, profileFlag, "", profileUsage)
+	addRootFlagCobra(rootCmd, outputFlag, string(JSON), outputUsage)
+	addRootFlagCobra(rootCmd, queryFlag, "", queryUsage)
+	addRootFlagCobra(rootCmd, validateFlag, string(ValidateOff), validateUsage)
+	addRootFlagCobra(rootCmd, timeoutFlag, "", timeoutUsage)
+	addRootFlagCobra(rootCmd, recordFlag, "", recordUsage)
+	addRootFlagCobra(rootCmd, replayFlag, "", replayUsage)
+	addVerboseFlagCobra(rootCmd)
+	addAuthFlagsCobra(rootCmd, &model.Model)
+	addAuthCommandsCobra(rootCmd, &model.Model, o)
 
 	for path, item := range model.Model.Paths.PathItems.FromOldest() {
 		for method, op := range item.GetOperations().FromOldest() {
@@ -132,8 +516,49 @@ func BootstrapV3Cobra(rootCmd *cobra.Command, model libopenapi.DocumentModel[v3.
 				continue
 			}
 
-			hData := HandlerData{Method: method, Path: path}
-			addParams(&cmd, op, &hData)
+			columns, err := makeColumns(op, exts)
+			if err != nil {
+				return err
+			}
+
+			pagination, err := makePagination(op, exts)
+			if err != nil {
+				return err
+			}
+
+			longRunning, err := makeLongRunning(op, exts)
+			if err != nil {
+				return err
+			}
+
+			retry, err := makeRetry(method, op, exts, o.retry)
+			if err != nil {
+				return err
+			}
+
+			waiter, err := makeWaiter(&model.Model, op, exts)
+			if err != nil {
+				return err
+			}
+			if waiter != nil {
+				waiters = append(waiters, waiter)
+			}
+
+			hData := HandlerData{
+				Method:      method,
+				Path:        path,
+				Columns:     columns,
+				Pagination:  pagination,
+				LongRunning: longRunning,
+				Retry:       retry,
+				Streaming:   streamingMediaType(op),
+				Binary:      isBinaryOperation(op),
+				responses:   op.Responses,
+				client:      o.httpClient,
+			}
+			if err := addParams(&cmd, op, &hData, rootName); err != nil {
+				return err
+			}
 			if err := addRequestBodyCobra(&cmd, op, &hData); err != nil {
 				return err
 			}
@@ -144,6 +569,26 @@ func BootstrapV3Cobra(rootCmd *cobra.Command, model libopenapi.DocumentModel[v3.
 				continue
 			}
 
+			// Only fails when asked for, eg for a body without a schema
+			skeleton, skeletonErr := makeSkeleton(op, &hData)
+			addSkeletonCobra(&cmd)
+			if pagination != nil {
+				addPaginationCobra(&cmd)
+			}
+			if longRunning != nil {
+				cmd.Flags().Bool(noWaitFlag, false, noWaitUsage)
+			}
+			if hData.Binary {
+				cmd.Flags().String(outputFileFlag, "", outputFileUsage)
+				cmd.Flags().Bool(forceFlag, false, forceUsage)
+			}
+			cmd.Flags().Bool(dryRunFlag, false, dryRunUsage)
+			cmd.Flags().Bool(curlFlag, false, curlUsage)
+			if waiter != nil {
+				cmd.Flags().Bool(waitFlag, false, fmt.Sprintf(waitFlagUsage, waiter.Name))
+			}
+			addFlagSourcesCobra(&cmd, o)
+
 			cmd.Hidden = exts.hidden
 			cmd.Aliases = exts.aliases
 			cmd.Short = op.Description
@@ -151,11 +596,47 @@ func BootstrapV3Cobra(rootCmd *cobra.Command, model libopenapi.DocumentModel[v3.
 				cmd.Short = op.Summary
 			}
 			cmd.RunE = func(opts *cobra.Command, args []string) error {
-				if err := interpolatePathCobra(&cmd, &hData); err != nil {
+				if format, _ := opts.Flags().GetString(skeletonFlag); format != "" {
+					if skeletonErr != nil {
+						return skeletonErr
+					}
+
+					return writeSkeleton(opts.OutOrStdout(), skeleton, format)
+				}
+
+				timeout, _ := opts.Flags().GetString(timeoutFlag)
+				ctx, cancel, err := commandContext(opts.Context(), timeout)
+				if err != nil {
 					return err
 				}
+				defer cancel()
+				opts.SetContext(ctx)
+
+				data := hData
+				resolver, err := prepareCobra(opts, &model.Model, op, o, rootName, &data)
+				if err != nil {
+					return commandError(ctx, err)
+				}
+				defer data.archive.flush()
 
-				return handler(opts, args, hData)
+				wait, _ := opts.Flags().GetBool(waitFlag)
+				if waiter != nil && wait {
+					data.response = &operationResponse{}
+				}
+
+				err = handler(ctx, opts, args, data)
+				if errors.Is(err, ErrDryRun) {
+					return nil
+				}
+				if err != nil {
+					return o.exitCodes.wrap(commandError(ctx, err))
+				}
+
+				if waiter != nil && wait {
+					return o.exitCodes.wrap(commandError(ctx, waiter.waitAfter(ctx, opts.OutOrStdout(), data, resolver)))
+				}
+
+				return nil
 			}
 
 			cmd.Use = op.OperationId // default
@@ -188,7 +669,7 @@ func BootstrapV3Cobra(rootCmd *cobra.Command, model libopenapi.DocumentModel[v3.
 		rootCmd.AddCommand(&groupedCmd)
 	}
 
-	return nil
+	return addWaitersCobra(rootCmd, &model.Model, waiters, o)
 }
 
 // Bootstraps a cobra.Command with the loaded model and a handler map
@@ -1,7 +1,17 @@
 package climate
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
@@ -166,3 +176,583 @@ func TestBootstrapV3Cobra(t *testing.T) {
 
 	assert.NoError(t, rootCmd.Execute())
 }
+
+func TestGenerateSkeletonCobra(t *testing.T) {
+	model, err := LoadFileV3("api.yaml")
+	assert.NoError(t, err)
+
+	handler := func(opts *cobra.Command, args []string, data HandlerData) error {
+		t.Fatal("handler must not be called when generating a skeleton")
+
+		return nil
+	}
+	rootCmd := &cobra.Command{Use: "calc"}
+
+	err = BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{"AddPost": handler})
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"ops", "add-post", "--generate-skeleton"})
+
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, "{\n  \"n1\": 42,\n  \"n2\": 0\n}\n", out.String())
+
+	out.Reset()
+	rootCmd.SetArgs([]string{"ops", "add-post", "--generate-skeleton=yaml"})
+
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, "n1: 42\nn2: 0\n", out.String())
+}
+
+func TestCredentialsCobra(t *testing.T) {
+	model, err := LoadV3([]byte(securitySpec))
+	assert.NoError(t, err)
+
+	var creds []Credential
+	handler := func(opts *cobra.Command, args []string, data HandlerData) error {
+		creds = data.Credentials
+
+		return nil
+	}
+	rootCmd := &cobra.Command{Use: "secure"}
+	handlers := map[string]HandlerCobra{
+		"Inherited": handler,
+		"Combined":  handler,
+	}
+
+	err = BootstrapV3Cobra(rootCmd, *model, handlers, WithCredentialStore(NewMemoryStore()))
+	assert.NoError(t, err)
+
+	for _, flag := range []string{"api-key", "user", "token"} {
+		assert.NotNilf(t, rootCmd.PersistentFlags().Lookup(flag), "Flag: %s", flag)
+	}
+
+	t.Setenv("SECURE_API_KEY", "key")
+	rootCmd.SetArgs([]string{"Combined", "--token", "tok"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(
+		t,
+		[]Credential{
+			{Scheme: "keyAuth", Type: APIKey, In: "header", Name: "X-API-Key", Value: "key"},
+			{Scheme: "bearerAuth", Type: Bearer, Value: "tok"},
+		},
+		creds,
+	)
+}
+
+func TestClientCredentialsCobra(t *testing.T) {
+	var grants []string
+	server, _ := tokenServer(t, &grants)
+	model, err := LoadV3([]byte(fmt.Sprintf(oauth2Spec, server.URL)))
+	assert.NoError(t, err)
+
+	var creds []Credential
+	handler := func(opts *cobra.Command, args []string, data HandlerData) error {
+		creds = data.Credentials
+
+		return nil
+	}
+	rootCmd := &cobra.Command{Use: "reports"}
+
+	err = BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{"GetReports": handler}, WithCredentialStore(NewMemoryStore()))
+	assert.NoError(t, err)
+
+	t.Setenv("REPORTS_CLIENT_SECRET", "s3cret")
+	rootCmd.SetArgs([]string{"GetReports", "--client-id", "reporter"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, []Credential{{Scheme: "machine", Type: Bearer, Value: "token-1"}}, creds)
+}
+
+func TestLoginCobra(t *testing.T) {
+	fakeBrowser(t)
+
+	server := authServer(t, false)
+	model, err := LoadV3([]byte(fmt.Sprintf(loginSpec, server.URL)))
+	assert.NoError(t, err)
+
+	var creds []Credential
+	handler := func(opts *cobra.Command, args []string, data HandlerData) error {
+		creds = data.Credentials
+
+		return nil
+	}
+	rootCmd := &cobra.Command{Use: "me"}
+
+	err = BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{"GetMe": handler}, WithCredentialStore(NewMemoryStore()))
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"login", "--client-id", "cli"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Contains(t, out.String(), "Logged in to user")
+
+	rootCmd.SetArgs([]string{"GetMe"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, []Credential{{Scheme: "user", Type: Bearer, Value: "user-token"}}, creds)
+
+	// Waiting for the browser gives up like the operations
+	rootCmd = &cobra.Command{Use: "me"}
+	err = BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{"GetMe": handler}, WithCredentialStore(NewMemoryStore()))
+	assert.NoError(t, err)
+
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	rootCmd.SetArgs([]string{"login", "--client-id", "cli", "--no-browser", "--timeout", "20ms"})
+	err = rootCmd.Execute()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, DefaultExitCodes.Timeout, ExitCode(err))
+}
+
+func TestDeviceLoginCobra(t *testing.T) {
+	fastDevicePolling(t)
+
+	server, _ := deviceServer(t, "authorization_pending")
+	model, err := LoadV3([]byte(fmt.Sprintf(deviceSpec, server.URL)))
+	assert.NoError(t, err)
+
+	rootCmd := &cobra.Command{Use: "me"}
+	handler := func(opts *cobra.Command, args []string, data HandlerData) error { return nil }
+
+	store := NewMemoryStore()
+	err = BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{"GetMe": handler}, WithCredentialStore(store))
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"login", "--device", "--client-id", "cli"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Contains(t, out.String(), "enter the code: WDJB-MJHT")
+
+	stored, err := store.Get(defaultProfile, "sso")
+	assert.NoError(t, err)
+	assert.Equal(t, "device-token", stored.Value)
+}
+
+func TestAuthCommandsCobra(t *testing.T) {
+	model, err := LoadV3([]byte(securitySpec))
+	assert.NoError(t, err)
+
+	store := NewMemoryStore()
+	assert.NoError(t, store.Set(defaultProfile, "bearerAuth", StoredCredential{Value: "tok"}))
+
+	var creds []Credential
+	handler := func(opts *cobra.Command, args []string, data HandlerData) error {
+		creds = data.Credentials
+
+		return nil
+	}
+	rootCmd := &cobra.Command{Use: "secure"}
+
+	err = BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{"Inherited": handler}, WithCredentialStore(store))
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+
+	rootCmd.SetArgs([]string{"Inherited"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, []Credential{{Scheme: "bearerAuth", Type: Bearer, Value: "tok"}}, creds)
+
+	rootCmd.SetArgs([]string{"auth", "status"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Contains(t, out.String(), "bearerAuth (http): logged in")
+
+	rootCmd.SetArgs([]string{"logout", "--scheme", "bearerAuth"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Contains(t, out.String(), "Logged out of bearerAuth")
+
+	stored, err := store.Get(defaultProfile, "bearerAuth")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestProfilesCobra(t *testing.T) {
+	model, err := LoadV3([]byte(profileSpec))
+	assert.NoError(t, err)
+
+	config := WithConfigFile(writeConfig(t, profileConfig))
+
+	var (
+		data   HandlerData
+		values []any
+	)
+	run := func(args ...string) error {
+		handler := func(opts *cobra.Command, args []string, d HandlerData) error {
+			owner, _ := opts.Flags().GetString("owner")
+			limit, _ := opts.Flags().GetInt("limit")
+			sort, _ := opts.Flags().GetString("sort")
+			data, values = d, []any{owner, limit, sort}
+
+			return nil
+		}
+		rootCmd := &cobra.Command{Use: "profiles", SilenceUsage: true}
+
+		err := BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{"ListItems": handler}, config)
+		assert.NoError(t, err)
+
+		rootCmd.SetArgs(args)
+
+		return rootCmd.Execute()
+	}
+
+	assert.NoError(t, run("ListItems"))
+	assert.Equal(t, "https://dev.example.com/v1", data.Server)
+	assert.Equal(t, []any{"me", 50, "name"}, values)
+	assert.Equal(t, JSON, data.Output)
+	assert.False(t, data.outputSet)
+
+	assert.NoError(t, run("ListItems", "--output", "table"))
+	assert.Equal(t, Table, data.Output)
+	assert.True(t, data.outputSet)
+	assert.ErrorContains(t, run("ListItems", "--output", "xml"), "Unknown output format xml")
+
+	assert.NoError(t, run("ListItems", "--query", "$[*].name"))
+	assert.Equal(t, "$[*].name", data.Query)
+	assert.ErrorContains(t, run("ListItems", "--query", "$["), "Invalid query")
+
+	assert.NoError(t, run("ListItems", "--validate-response", "strict"))
+	assert.Equal(t, ValidateStrict, data.Validation)
+	assert.ErrorContains(t, run("ListItems", "--validate-response", "loud"), "Unknown validation mode loud")
+	assert.Equal(t, []Credential{{Scheme: "bearerAuth", Type: Bearer, Value: "dev-token"}}, data.Credentials)
+
+	t.Setenv("PROFILES_TOKEN", "env-token")
+	assert.NoError(t, run("ListItems", "--limit", "5"))
+	assert.Equal(t, []any{"me", 5, "name"}, values)
+	assert.Equal(t, "env-token", data.Credentials[0].Value)
+
+	t.Setenv("PROFILES_LIMIT", "20")
+	t.Setenv("ITEMS_SORT", "date")
+	assert.NoError(t, run("ListItems"))
+	assert.Equal(t, []any{"me", 20, "date"}, values)
+
+	assert.NoError(t, run("ListItems", "--sort", "size"))
+	assert.Equal(t, []any{"me", 20, "size"}, values)
+
+	t.Setenv("PROFILES_LIMIT", "many")
+	assert.ErrorContains(t, run("ListItems"), "many")
+	os.Unsetenv("PROFILES_LIMIT")
+	os.Unsetenv("ITEMS_SORT")
+
+	assert.ErrorContains(t, run("ListItems", "--profile", "local"), `"owner" not set`)
+
+	t.Setenv("PROFILES_PROFILE", "local")
+	assert.NoError(t, run("ListItems", "--owner", "you"))
+	assert.Equal(t, "http://localhost:9000", data.Server)
+	assert.Equal(t, []any{"you", 10, "name"}, values)
+
+	assert.ErrorContains(t, run("ListItems", "--profile", "prod"), "Unknown profile prod")
+}
+
+func TestExitCodesCobra(t *testing.T) {
+	model, err := LoadV3([]byte(profileSpec))
+	assert.NoError(t, err)
+
+	run := func(handlerErr error, opts ...Option) error {
+		handler := func(opts *cobra.Command, args []string, data HandlerData) error {
+			return handlerErr
+		}
+		rootCmd := &cobra.Command{Use: "profiles", SilenceUsage: true, SilenceErrors: true}
+
+		opts = append(opts, WithConfigFile(writeConfig(t, profileConfig)))
+		err := BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{"ListItems": handler}, opts...)
+		assert.NoError(t, err)
+
+		rootCmd.SetArgs([]string{"ListItems"})
+
+		return rootCmd.Execute()
+	}
+
+	assert.Equal(t, 0, ExitCode(run(nil)))
+	assert.Equal(t, 2, ExitCode(run(&HTTPError{Status: 404})))
+	assert.Equal(t, 3, ExitCode(run(&HTTPError{Status: 500})))
+	assert.Equal(t, 1, ExitCode(run(fmt.Errorf("Something else"))))
+	assert.Equal(t, 10, ExitCode(run(&HTTPError{Status: 404}, WithExitCodes(ExitCodes{Statuses: map[int]int{404: 10}}))))
+}
+
+func TestPaginationCobra(t *testing.T) {
+	model, err := LoadV3([]byte(paginateSpec))
+	assert.NoError(t, err)
+
+	var data HandlerData
+	handler := func(opts *cobra.Command, args []string, d HandlerData) error {
+		data = d
+		return nil
+	}
+	rootCmd := &cobra.Command{Use: "pages", SilenceUsage: true}
+	handlers := map[string]HandlerCobra{"ListByCursor": handler, "ListByOffset": handler}
+
+	assert.ErrorContains(t, BootstrapV3Cobra(rootCmd, *model, handlers), "Invalid x-cli-pagination in Broken")
+
+	model.Model.Paths.PathItems.Delete("/broken")
+	rootCmd = &cobra.Command{Use: "pages", SilenceUsage: true}
+	assert.NoError(t, BootstrapV3Cobra(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+
+	rootCmd.SetArgs([]string{"ListByCursor", "--max-items", "5"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, CursorPagination, data.Pagination.Strategy)
+	assert.False(t, data.AllPages)
+	assert.Equal(t, 5, data.MaxItems)
+	assert.Equal(t, DefaultRetry.Attempts, data.Retry.Attempts)
+	assert.Equal(t, 500*time.Millisecond, data.Retry.backoff)
+
+	rootCmd.SetArgs([]string{"ListByOffset", "--all"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, OffsetPagination, data.Pagination.Strategy)
+	assert.True(t, data.AllPages)
+}
+
+func TestWaitersCobra(t *testing.T) {
+	server := clustersServer(t)
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, waiterSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerCobra{
+		"CreateCluster": func(opts *cobra.Command, args []string, data HandlerData) error {
+			req, err := data.NewRequest(opts.Context(), nil)
+			if err != nil {
+				return err
+			}
+
+			return data.Send(opts.OutOrStdout(), req)
+		},
+	}
+	handlers["GenerateCluster"] = handlers["CreateCluster"]
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		rootCmd := &cobra.Command{Use: "clusters", SilenceUsage: true, SilenceErrors: true}
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(args)
+		assert.NoError(t, BootstrapV3Cobra(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+		err := rootCmd.Execute()
+
+		return out.String(), err
+	}
+
+	out, err := run("CreateCluster", "--name", "ok")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"ok","status":"PROVISIONING"}`+"\n", out)
+
+	out, err = run("CreateCluster", "--name", "ok", "--wait")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"ok","status":"PROVISIONING"}`+"\n"+`{"name":"ok","status":"READY"}`+"\n", out)
+
+	// The cluster to wait for is the one in the response
+	out, err = run("GenerateCluster", "--wait")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"ok","status":"PROVISIONING"}`+"\n"+`{"name":"ok","status":"READY"}`+"\n", out)
+
+	out, err = run("wait", "cluster-ready", "--name", "ok", "--output", "table")
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME   STATUS\nok     READY\n", out)
+
+	_, err = run("wait", "cluster-ready", "--name", "bad")
+	assert.EqualError(t, err, "Waiting for cluster-ready failed, the state is FAILED")
+	assert.Equal(t, DefaultExitCodes.WaitFailure, ExitCode(err))
+
+	_, err = run("wait", "cluster-ready")
+	assert.ErrorContains(t, err, `required flag(s) "name" not set`)
+}
+
+func TestLongRunningCobra(t *testing.T) {
+	model, err := LoadV3([]byte(lroSpec))
+	assert.NoError(t, err)
+	model.Model.Paths.PathItems.Delete("/broken")
+
+	var data HandlerData
+	handler := func(opts *cobra.Command, args []string, d HandlerData) error {
+		data = d
+		return nil
+	}
+	handlers := map[string]HandlerCobra{"StartJob": handler, "ListJobs": handler, "DeleteJob": handler}
+	run := func(args ...string) error {
+		rootCmd := &cobra.Command{Use: "jobs", SilenceUsage: true, SilenceErrors: true}
+		rootCmd.SetArgs(args)
+		assert.NoError(t, BootstrapV3Cobra(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+
+		return rootCmd.Execute()
+	}
+
+	assert.NoError(t, run("StartJob", "--no-wait"))
+	assert.NotNil(t, data.LongRunning)
+	assert.True(t, data.NoWait)
+
+	assert.NoError(t, run("StartJob"))
+	assert.False(t, data.NoWait)
+
+	assert.NoError(t, run("ListJobs"))
+	assert.Nil(t, data.LongRunning)
+	assert.ErrorContains(t, run("ListJobs", "--no-wait"), "unknown flag: --no-wait")
+
+	assert.NoError(t, run("DeleteJob", "--id", "1"))
+	assert.Nil(t, data.LongRunning)
+}
+
+func TestContextCobra(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, waiterSpec, server.URL))
+	assert.NoError(t, err)
+
+	var ctx context.Context
+	handlers := map[string]HandlerCobraCtx{
+		"CreateCluster": func(c context.Context, opts *cobra.Command, args []string, data HandlerData) error {
+			ctx = c
+			assert.Equal(t, c, opts.Context())
+
+			req, err := data.NewRequest(c, nil)
+			if err != nil {
+				return err
+			}
+
+			return data.Send(io.Discard, req)
+		},
+	}
+	run := func(args ...string) error {
+		rootCmd := &cobra.Command{Use: "clusters", SilenceUsage: true, SilenceErrors: true}
+		rootCmd.SetArgs(args)
+		assert.NoError(t, BootstrapV3CobraCtx(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+
+		return rootCmd.Execute()
+	}
+
+	err = run("CreateCluster", "--name", "ok", "--timeout", "20ms")
+	assert.ErrorContains(t, err, "Timed out after 20ms")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, DefaultExitCodes.Timeout, ExitCode(err))
+	assert.Equal(t, &TimeoutError{Timeout: 20 * time.Millisecond}, context.Cause(ctx))
+
+	assert.EqualError(t, run("CreateCluster", "--name", "ok", "--timeout", "soon"), "Invalid timeout soon, use a positive duration like 30s")
+
+	// The context is there for the existing handlers too
+	rootCmd := &cobra.Command{Use: "clusters", SilenceUsage: true}
+	rootCmd.SetArgs([]string{"CreateCluster", "--name", "ok", "--timeout", "1m"})
+	assert.NoError(t, BootstrapV3Cobra(rootCmd, *model, map[string]HandlerCobra{
+		"CreateCluster": func(opts *cobra.Command, args []string, data HandlerData) error {
+			_, ok := opts.Context().Deadline()
+			assert.True(t, ok)
+
+			return nil
+		},
+	}, WithConfigFile(writeConfig(t, ""))))
+	assert.NoError(t, rootCmd.Execute())
+}
+
+func TestDryRunCobra(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The request was sent")
+	}))
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, dryRunSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerCobra{
+		"PutItem": func(opts *cobra.Command, args []string, data HandlerData) error {
+			req, err := data.NewRequest(opts.Context(), strings.NewReader(`{"name":"a"}`))
+			if err != nil {
+				return err
+			}
+
+			return data.Send(opts.OutOrStdout(), req)
+		},
+	}
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		rootCmd := &cobra.Command{Use: "items", SilenceUsage: true, SilenceErrors: true}
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(args)
+		assert.NoError(t, BootstrapV3Cobra(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+		err := rootCmd.Execute()
+
+		return out.String(), err
+	}
+
+	out, err := run("PutItem", "--id", "i1", "--dry", "--token", "t1", "--dry-run")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("PUT %s/items/i1?dry=true\nAuthorization: Bearer ***\n\n{\"name\":\"a\"}\n", server.URL), out)
+
+	out, err = run("PutItem", "--id", "i1", "--token", "t1", "--curl")
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("curl -X PUT '%s/items/i1' \\\n  -H 'Authorization: Bearer ***' \\\n  --data-binary '{\"name\":\"a\"}'\n", server.URL), out)
+}
+
+func TestVerboseCobra(t *testing.T) {
+	server := accountsServer(t)
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, traceSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerCobra{
+		"CreateAccount": func(opts *cobra.Command, args []string, data HandlerData) error {
+			account, _ := opts.Flags().GetString("account")
+			req, err := data.NewRequest(opts.Context(), strings.NewReader(account))
+			if err != nil {
+				return err
+			}
+
+			return data.Send(io.Discard, req)
+		},
+	}
+	var log bytes.Buffer
+	rootCmd := &cobra.Command{Use: "accounts", SilenceUsage: true, SilenceErrors: true}
+	rootCmd.SetErr(&log)
+	rootCmd.SetArgs([]string{"CreateAccount", "--api-key", "k1", "--account", `{"credentials":[{"secret":"p1"}]}`, "-vv"})
+	assert.NoError(t, BootstrapV3Cobra(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+	assert.NoError(t, rootCmd.Execute())
+
+	assert.Contains(t, log.String(), "> X-Api-Key: ***\n")
+	assert.Contains(t, log.String(), "\n"+`{"credentials":[{"secret":"***"}]}`+"\n")
+	assert.NotContains(t, log.String(), "p1")
+}
+
+func TestRecordReplayCobra(t *testing.T) {
+	server := accountsServer(t)
+	model, err := LoadV3(fmt.Appendf(nil, traceSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerCobra{
+		"CreateAccount": func(opts *cobra.Command, args []string, data HandlerData) error {
+			account, _ := opts.Flags().GetString("account")
+			req, err := data.NewRequest(opts.Context(), strings.NewReader(account))
+			if err != nil {
+				return err
+			}
+
+			return data.Send(opts.OutOrStdout(), req)
+		},
+	}
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		rootCmd := &cobra.Command{Use: "accounts", SilenceUsage: true, SilenceErrors: true}
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(append([]string{"CreateAccount", "--api-key", "k1", "--account", `{"name":"a"}`}, args...))
+		assert.NoError(t, BootstrapV3Cobra(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+		err := rootCmd.Execute()
+
+		return out.String(), err
+	}
+
+	path := filepath.Join(t.TempDir(), "session.har")
+	recorded, err := run("--record", path)
+	assert.NoError(t, err)
+	assert.Contains(t, recorded, `"name"`)
+	server.Close()
+
+	replayed, err := run("--replay", path)
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	_, err = run("--replay", path, "--record", path)
+	assert.EqualError(t, err, "Use either --record or --replay, not both")
+}
@@ -9,8 +9,11 @@ package climate
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
+	"slices"
 
 	"github.com/pb33f/libopenapi"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
@@ -36,21 +39,118 @@ type ParamMeta struct {
 
 // Data passed into each handler
 type HandlerData struct {
-	Method           string      // the HTTP method
-	Path             string      // the path with the path params filled in
-	PathParams       []ParamMeta // List of path params
-	QueryParams      []ParamMeta // List of query params
-	HeaderParams     []ParamMeta // List of header params
-	CookieParams     []ParamMeta // List of cookie params
-	RequestBodyParam *ParamMeta  // The optional request body
+	Method           string         // the HTTP method
+	Server           string         // the base URL of the server from the profile or the spec
+	Path             string         // the path with the path params filled in
+	PathParams       []ParamMeta    // List of path params
+	QueryParams      []ParamMeta    // List of query params
+	HeaderParams     []ParamMeta    // List of header params
+	CookieParams     []ParamMeta    // List of cookie params
+	RequestBodyParam *ParamMeta     // The optional request body
+	Credentials      []Credential   // The credentials satisfying the security requirements
+	Output           OutputFormat   // the output format to render the response in
+	Columns          []Column       // the columns of the table output
+	Query            string         // the JSONPath selecting what to render from the response
+	Validation       ValidationMode // how to check responses against the spec
+	Pagination       *Pagination    // how to follow the pages of a list operation, nil if not paginated
+	AllPages         bool           // whether to fetch all the pages
+	MaxItems         int            // the number of items to fetch pages until, 0 for no limit
+	LongRunning      *LongRunning   // how a 202 Accepted is polled, nil if the operation isn't long-running
+	NoWait           bool           // whether to return a 202 Accepted without polling
+	Retry            *Retry         // how failed requests are retried by Do, nil if they aren't
+	Streaming        string         // the media type of the responses if streamed, eg text/event-stream
+	Binary           bool           // whether the responses are binary, written to a file by Send
+	OutputFile       string         // the file to write binary responses to, - for stdout. The Content-Disposition filename if empty
+	Force            bool           // whether to write binary responses to a terminal or over an existing file
+	DryRun           bool           // whether Do prints the requests instead of sending them
+	Curl             bool           // whether Do prints the requests as curl commands instead of sending them
+	Verbose          int            // how much of the requests and responses Do logs: 1 for their headers and timing, 2 for their bodies too
+
+	responses *v3.Responses                    // of the operation, to validate against
+	outputSet bool                             // when the output format is chosen rather than the default
+	client    *http.Client                     // sends the requests of Do
+	param     func(name string) (string, bool) // the value of the flag of a param and whether it's set
+	out       io.Writer                        // where dry runs are printed, stdout if nil
+	errOut    io.Writer                        // where requests are traced, stderr if nil
+	secrets   []secret                         // the params masked when requests are printed, traced or recorded
+	archive   *archive                         // the HAR file requests are recorded to or replayed from, nil for neither
+	response  *operationResponse               // keeps the response of the operation for its waiter, nil unless waiting after it
+}
+
+// Customizes how the commands are bootstrapped
+type Option func(*options)
+
+type options struct {
+	credentialStore CredentialStore
+	configFile      string
+	exitCodes       ExitCodes
+	httpClient      *http.Client
+	retry           Retry
+}
+
+// Keeps the credentials from logging in in the given store instead of the default file
+func WithCredentialStore(store CredentialStore) Option {
+	return func(o *options) {
+		o.credentialStore = store
+	}
+}
+
+// Reads the profiles from the given config file instead of the one in the user's config dir
+func WithConfigFile(path string) Option {
+	return func(o *options) {
+		o.configFile = path
+	}
+}
+
+// Maps the errors returned by handlers to these exit codes instead of DefaultExitCodes
+func WithExitCodes(codes ExitCodes) Option {
+	return func(o *options) {
+		o.exitCodes = codes
+	}
+}
+
+// Sends the requests of HandlerData.Do and for tokens with the given client instead of http.DefaultClient
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// Retries the failed requests of the operations as set instead of with DefaultRetry, Attempts of 1 turns it off
+func WithRetry(retry Retry) Option {
+	return func(o *options) {
+		o.retry = retry
+	}
+}
+
+func makeOptions(rootName string, opts []Option) *options {
+	o := &options{configFile: defaultConfigPath(rootName), exitCodes: DefaultExitCodes, retry: DefaultRetry}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.credentialStore == nil {
+		o.credentialStore = &lazyStore{rootName: rootName}
+	}
+
+	return o
 }
 
 type extensions struct {
-	hidden  bool
-	aliases []string
-	group   string
-	ignored bool
-	name    string
+	hidden                 bool
+	aliases                []string
+	group                  string
+	ignored                bool
+	name                   string
+	deviceAuthorizationURL string
+	env                    string
+	columns                []Column
+	pagination             *Pagination
+	waiter                 *Waiter
+	longRunning            *LongRunning
+	longRunningOff         bool // when inferring it from a declared 202 is turned off
+	retry                  *Retry
+	retryOff               bool
 }
 
 func parseExtensions(exts *orderedmap.Map[string, *yaml.Node]) (*extensions, error) {
@@ -77,6 +177,52 @@ func parseExtensions(exts *orderedmap.Map[string, *yaml.Node]) (*extensions, err
 			ex.ignored = opts.(bool)
 		case "x-cli-name":
 			ex.name = opts.(string)
+		case "x-cli-device-authorization-url":
+			ex.deviceAuthorizationURL = opts.(string)
+		case "x-cli-env":
+			ex.env = opts.(string)
+		case "x-cli-columns":
+			if err := val.Decode(&ex.columns); err != nil {
+				return nil, err
+			}
+		case "x-cli-pagination":
+			ex.pagination = &Pagination{}
+			if err := val.Decode(ex.pagination); err != nil {
+				return nil, err
+			}
+		case "x-cli-waiter":
+			ex.waiter = &Waiter{}
+			if err := val.Decode(ex.waiter); err != nil {
+				return nil, err
+			}
+		case "x-cli-long-running":
+			if on, ok := opts.(bool); ok {
+				ex.longRunningOff = !on
+				if on {
+					ex.longRunning = &LongRunning{}
+				}
+
+				continue
+			}
+
+			ex.longRunning = &LongRunning{}
+			if err := val.Decode(ex.longRunning); err != nil {
+				return nil, err
+			}
+		case "x-cli-retry":
+			if on, ok := opts.(bool); ok {
+				ex.retryOff = !on
+				if on {
+					ex.retry = &Retry{}
+				}
+
+				continue
+			}
+
+			ex.retry = &Retry{}
+			if err := val.Decode(ex.retry); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -119,6 +265,64 @@ func getParamType(param *v3.Parameter, op *v3.Operation) OpenAPIType {
 	return String
 }
 
+// The params of an operation with flags by where they go
+func paramMetas(op *v3.Operation) (path, query, header, cookie []ParamMeta) {
+	for _, param := range op.Parameters {
+		meta := ParamMeta{Name: param.Name, Type: getParamType(param, op)}
+		if !slices.Contains([]OpenAPIType{String, Integer, Number, Boolean}, meta.Type) {
+			continue
+		}
+
+		switch param.In {
+		case "path":
+			path = append(path, meta)
+		case "query":
+			query = append(query, meta)
+		case "header":
+			header = append(header, meta)
+		case "cookie":
+			cookie = append(cookie, meta)
+		}
+	}
+
+	return path, query, header, cookie
+}
+
+// Flags with these values are left out of requests unless set
+func isZeroFlag(value string) bool {
+	return value == "" || value == "0" || value == "false"
+}
+
+// The env var binding the flag of a param, the one set via x-cli-env or named after the root command
+func paramEnvVar(rootName string, param *v3.Parameter) (string, error) {
+	exts, err := parseExtensions(param.Extensions)
+	if err != nil {
+		return "", err
+	}
+
+	if exts.env != "" {
+		return exts.env, nil
+	}
+
+	return envVarName(rootName, param.Name), nil
+}
+
+// Decodes the default of a param from its schema into v, leaving it as is when unset
+func paramDefault(param *v3.Parameter, v any) {
+	if param.Schema == nil {
+		return
+	}
+
+	schema := param.Schema.Schema()
+	if schema == nil || schema.Default == nil {
+		return
+	}
+
+	if err := schema.Default.Decode(v); err != nil {
+		slog.Warn("Invalid default for param, ignoring", "param", param.Name, "error", err)
+	}
+}
+
 func makeRequestBody(op *v3.Operation, handlerData *HandlerData) (name string, desc string, required bool, err error) {
 	if body := op.RequestBody; body != nil {
 		// TODO: hammock on ways to handle the req bodies. Maybe take in a stdin?
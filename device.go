@@ -0,0 +1,149 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Polling intervals as per RFC 8628
+var (
+	deviceDefaultInterval = 5 * time.Second
+	deviceSlowDown        = 5 * time.Second
+)
+
+var errDeviceCodeExpired = errors.New("The device code expired before logging in")
+
+// The response of the device authorization endpoint
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// Logs in via the device authorization grant as in RFC 8628.
+// The user enters the printed code on any device while the token endpoint is polled.
+func (r credentialResolver) deviceGrant(ctx context.Context, w io.Writer, flows loginFlows, clientID string) (*oauth2Token, error) {
+	form := url.Values{"client_id": {clientID}}
+	if scopes := flows.device.Scopes; scopes != nil && scopes.Len() > 0 {
+		form.Set("scope", strings.Join(slices.Collect(scopes.KeysFromOldest()), " "))
+	}
+
+	auth, err := r.authorizeDevice(ctx, flows.deviceURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(w, "Open %s and enter the code: %s\n", auth.VerificationURI, auth.UserCode)
+	if auth.VerificationURIComplete != "" {
+		fmt.Fprintf(w, "Or open %s\n", auth.VerificationURIComplete)
+	}
+
+	interval := deviceDefaultInterval
+	if auth.Interval > 0 {
+		interval = time.Duration(auth.Interval) * time.Second
+	}
+
+	if auth.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, time.Duration(auth.ExpiresIn)*time.Second, errDeviceCodeExpired)
+		defer cancel()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The expiry of the code, or eg the --timeout of the command
+			return nil, context.Cause(ctx)
+		case <-time.After(interval):
+		}
+
+		token, err := r.requestToken(ctx, flows.device.TokenUrl, clientID, r.lookup(clientSecretFlag), url.Values{
+			"grant_type":  {deviceCodeGrant},
+			"device_code": {auth.DeviceCode},
+		})
+		if err == nil {
+			return token, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+
+		var tokenErr *oauth2Error
+		if !errors.As(err, &tokenErr) {
+			return nil, err
+		}
+
+		switch tokenErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += deviceSlowDown
+		default:
+			return nil, err
+		}
+	}
+}
+
+func (r credentialResolver) authorizeDevice(ctx context.Context, deviceURL string, form url.Values) (*deviceAuthorization, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, deviceURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := r.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		authErr := &oauth2Error{Status: resp.Status}
+		json.Unmarshal(body, authErr)
+
+		return nil, authErr
+	}
+
+	var auth deviceAuthorization
+	if err := json.Unmarshal(body, &auth); err != nil {
+		return nil, fmt.Errorf("Invalid device authorization response: %w", err)
+	}
+
+	if auth.DeviceCode == "" || auth.UserCode == "" {
+		return nil, errors.New("Device authorization returned no device_code or user_code")
+	}
+
+	return &auth, nil
+}
@@ -0,0 +1,168 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const deviceSpec = `
+openapi: "3.0.0"
+info:
+  title: Device
+  version: "0.1.0"
+security:
+  - sso: []
+paths:
+  "/me":
+    get:
+      operationId: GetMe
+components:
+  securitySchemes:
+    sso:
+      type: oauth2
+      flows:
+        authorizationCode:
+          authorizationUrl: %[1]s/authorize
+          tokenUrl: %[1]s/token
+          x-cli-device-authorization-url: %[1]s/device
+          scopes:
+            profile: Read the profile
+    headless:
+      type: oauth2
+      x-cli-device-authorization-url: %[1]s/device
+      flows:
+        clientCredentials:
+          tokenUrl: %[1]s/token
+          scopes: {}
+`
+
+func fastDevicePolling(t *testing.T) {
+	interval, slowDown := deviceDefaultInterval, deviceSlowDown
+	deviceDefaultInterval, deviceSlowDown = 10*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { deviceDefaultInterval, deviceSlowDown = interval, slowDown })
+}
+
+// A device authorization server answering the polls with the given errors before issuing a token
+func deviceServer(t *testing.T, answers ...string) (*httptest.Server, *[]time.Time) {
+	var polls []time.Time
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "cli", r.Form.Get("client_id"))
+		assert.Equal(t, "profile", r.Form.Get("scope"))
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"device_code":      "the-device-code",
+			"user_code":        "WDJB-MJHT",
+			"verification_uri": "https://example.com/device",
+			"expires_in":       5,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, deviceCodeGrant, r.Form.Get("grant_type"))
+		assert.Equal(t, "the-device-code", r.Form.Get("device_code"))
+
+		polls = append(polls, time.Now())
+		if len(polls) <= len(answers) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": answers[len(polls)-1]})
+
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "device-token", "refresh_token": "device-refresh"})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, &polls
+}
+
+func TestDeviceLogin(t *testing.T) {
+	fastDevicePolling(t)
+
+	server, polls := deviceServer(t, "authorization_pending", "slow_down", "authorization_pending")
+	model, err := LoadV3([]byte(fmt.Sprintf(deviceSpec, server.URL)))
+	assert.NoError(t, err)
+
+	resolver := testResolver(&model.Model, "me", map[string]string{clientIDFlag: "cli"})
+
+	var out bytes.Buffer
+	assert.NoError(t, resolver.login(context.Background(), &out, "sso", false, true))
+	assert.Contains(t, out.String(), "Open https://example.com/device and enter the code: WDJB-MJHT")
+	assert.Contains(t, out.String(), "Logged in to sso")
+
+	assert.Len(t, *polls, 4)
+	// slowed down after the second poll
+	assert.GreaterOrEqual(t, (*polls)[2].Sub((*polls)[1]), 20*time.Millisecond)
+
+	creds, err := resolver.resolve(context.Background(), model.Model.Paths.PathItems.GetOrZero("/me").Get)
+	assert.NoError(t, err)
+	assert.Equal(t, []Credential{{Scheme: "sso", Type: Bearer, Value: "device-token"}}, creds)
+}
+
+func TestDeviceLoginDenied(t *testing.T) {
+	fastDevicePolling(t)
+
+	server, polls := deviceServer(t, "authorization_pending", "access_denied")
+	model, err := LoadV3([]byte(fmt.Sprintf(deviceSpec, server.URL)))
+	assert.NoError(t, err)
+
+	resolver := testResolver(&model.Model, "me", map[string]string{clientIDFlag: "cli"})
+
+	var out bytes.Buffer
+	assert.ErrorContains(t, resolver.login(context.Background(), &out, "sso", false, true), "access_denied")
+	assert.Len(t, *polls, 2)
+	stored, err := resolver.store.Get(defaultProfile, "sso")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestDeviceLoginTimeout(t *testing.T) {
+	fastDevicePolling(t)
+
+	server, _ := deviceServer(t, slices.Repeat([]string{"authorization_pending"}, 1000)...)
+	model, err := LoadV3([]byte(fmt.Sprintf(deviceSpec, server.URL)))
+	assert.NoError(t, err)
+
+	resolver := testResolver(&model.Model, "me", map[string]string{clientIDFlag: "cli"})
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 50*time.Millisecond, &TimeoutError{Timeout: 50 * time.Millisecond})
+	defer cancel()
+
+	// The timeout of the command isn't the expiry of the code
+	err = resolver.login(ctx, &bytes.Buffer{}, "sso", false, true)
+	var timeoutErr *TimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+	assert.NotErrorIs(t, err, errDeviceCodeExpired)
+}
+
+func TestLoginFlowsOf(t *testing.T) {
+	model, err := LoadV3([]byte(fmt.Sprintf(deviceSpec, "https://auth")))
+	assert.NoError(t, err)
+
+	schemes := model.Model.Components.SecuritySchemes
+
+	sso := loginFlowsOf(schemes.GetOrZero("sso"))
+	assert.NotNil(t, sso.authorizationCode)
+	assert.Equal(t, "https://auth/device", sso.deviceURL)
+	assert.Equal(t, sso.authorizationCode, sso.tokenFlow())
+
+	headless := loginFlowsOf(schemes.GetOrZero("headless"))
+	assert.Nil(t, headless.authorizationCode)
+	assert.Equal(t, "https://auth/device", headless.deviceURL)
+	assert.Equal(t, "https://auth/token", headless.tokenFlow().TokenUrl)
+
+	assert.Equal(t, []string{"sso", "headless"}, loginSchemes(&model.Model))
+}
@@ -0,0 +1,274 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+const (
+	binaryMediaType = "application/octet-stream"
+	outputFileFlag  = "output-file"
+	outputFileUsage = "Write the response to this file, - for stdout. Named after its Content-Disposition if unset"
+	forceFlag       = "force"
+	forceUsage      = "Write the response to the terminal or over an existing file"
+)
+
+// Defaults of downloading
+const (
+	downloadResumes  = 5 // in a row without receiving anything
+	progressInterval = 100 * time.Millisecond
+)
+
+// Whether a success response of the operation is binary: an application/octet-stream or a string in the binary format
+func isBinaryOperation(op *v3.Operation) bool {
+	if op.Responses == nil || op.Responses.Codes == nil {
+		return false
+	}
+
+	for code, resp := range op.Responses.Codes.FromOldest() {
+		if !strings.HasPrefix(code, "2") || resp == nil || resp.Content == nil {
+			continue
+		}
+
+		for mediaType, media := range resp.Content.FromOldest() {
+			if mediaType == binaryMediaType {
+				return true
+			}
+
+			if media.Schema != nil {
+				if schema := media.Schema.Schema(); schema != nil && schema.Format == "binary" {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// The filename of a Content-Disposition header without any directories, empty if there's none
+func dispositionFilename(header string) string {
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+
+	name := filepath.Base(strings.ReplaceAll(params["filename"], `\`, "/"))
+	if name == "." || name == ".." || name == "/" {
+		return ""
+	}
+
+	return name
+}
+
+// Writes the binary response of req to OutputFile, the Content-Disposition filename or else w unless it's a terminal.
+// Progress is shown on a terminal and the download is resumed with Range when the connection drops, if the server supports it.
+func (h HandlerData) download(w io.Writer, req *http.Request, resp *http.Response) (err error) {
+	defer func() {
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}()
+
+	path := h.OutputFile
+	if path == "" {
+		path = dispositionFilename(resp.Header.Get("Content-Disposition"))
+	}
+
+	resumable := resp.Header.Get("Accept-Ranges") == "bytes"
+	validator := resp.Header.Get("ETag")
+	if validator == "" {
+		validator = resp.Header.Get("Last-Modified")
+	}
+
+	var (
+		dst     io.Writer = w
+		written int64
+	)
+	if path == "" || path == "-" {
+		if isTerminal(w) && !h.Force {
+			return errors.New("Refusing to write binary to the terminal, use --output-file or --force")
+		}
+		path = ""
+	} else {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		// A name chosen by the server doesn't replace a file unless forced
+		if h.OutputFile == "" && !h.Force {
+			flags |= os.O_EXCL
+		}
+
+		f, openErr := os.OpenFile(path, flags, 0o644)
+		if errors.Is(openErr, os.ErrExist) {
+			return fmt.Errorf("File %s already exists, use --force to overwrite it", path)
+		}
+		if openErr != nil {
+			return openErr
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			switch {
+			case err == nil:
+			case resumable && written > 0 && interruptedError(err):
+				// Kept to be resumed from, eg with curl -C -
+				err = fmt.Errorf("%w, the %s downloaded are kept in %s", err, byteSize(written), path)
+			default:
+				os.Remove(path)
+			}
+		}()
+		dst = f
+	}
+
+	errOut := h.errOut
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+
+	var p *progress
+	if path != "" && isTerminal(errOut) {
+		p = &progress{w: errOut, name: path, total: resp.ContentLength}
+		dst = io.MultiWriter(dst, p)
+		defer p.finish()
+	}
+
+	for failures := 0; ; {
+		n, copyErr := io.Copy(dst, resp.Body)
+		resp.Body.Close()
+		written += n
+
+		if copyErr == nil {
+			return nil
+		}
+		if n > 0 {
+			failures = 0
+		}
+
+		// Resume from the bytes written so far
+		for resp = nil; resp == nil; {
+			if !resumable || req.Context().Err() != nil {
+				return copyErr
+			}
+
+			if failures++; failures > downloadResumes {
+				return copyErr
+			}
+
+			next, err := replayRequest(req)
+			if err != nil {
+				return copyErr
+			}
+			next.Header.Set("Range", fmt.Sprintf("bytes=%d-", written))
+			if validator != "" {
+				next.Header.Set("If-Range", validator)
+			}
+
+			if resp, err = h.Do(next); err != nil {
+				copyErr, resp = err, nil
+				continue
+			}
+
+			if resp.StatusCode != http.StatusPartialContent {
+				return fmt.Errorf("Cannot resume the download, the server answered %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+			}
+
+			if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != written {
+				return fmt.Errorf("Cannot resume the download at byte %d, the server sent the range %s", written, resp.Header.Get("Content-Range"))
+			}
+		}
+	}
+}
+
+// The first byte of a Content-Range like bytes 4000-9999/10000
+func contentRangeStart(header string) (int64, bool) {
+	unit, rest, ok := strings.Cut(header, " ")
+	if !ok || unit != "bytes" {
+		return 0, false
+	}
+
+	first, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+
+	return start, err == nil
+}
+
+// Whether a download stopped midway for the connection dropping or the command being stopped, rather than the server refusing it
+func interruptedError(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// Shows the bytes downloaded so far on a line of its own, at most every progressInterval
+type progress struct {
+	w       io.Writer
+	name    string
+	total   int64 // -1 when unknown
+	done    int64
+	printed time.Time
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if time.Since(p.printed) >= progressInterval {
+		p.print()
+	}
+
+	return len(b), nil
+}
+
+func (p *progress) print() {
+	p.printed = time.Now()
+
+	if p.total <= 0 {
+		fmt.Fprintf(p.w, "\rDownloading %s: %s", p.name, byteSize(p.done))
+		return
+	}
+
+	fmt.Fprintf(p.w, "\rDownloading %s: %s / %s (%d%%)", p.name, byteSize(p.done), byteSize(p.total), p.done*100/p.total)
+}
+
+func (p *progress) finish() {
+	p.print()
+	fmt.Fprintln(p.w)
+}
+
+// A size in the binary units, eg 1.5 MiB
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,284 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const downloadSpec = `
+openapi: "3.0.0"
+info:
+  title: Files
+  version: "0.1.0"
+paths:
+  "/report":
+    get:
+      operationId: GetReport
+      responses:
+        "200":
+          description: The report
+          content:
+            application/octet-stream: {}
+  "/avatar":
+    get:
+      operationId: GetAvatar
+      responses:
+        "200":
+          description: The avatar
+          content:
+            image/png:
+              schema:
+                type: string
+                format: binary
+  "/status":
+    get:
+      operationId: GetStatus
+      responses:
+        "200":
+          description: The status
+          content:
+            application/json:
+              schema:
+                type: object
+`
+
+var fileContent = strings.Repeat("0123456789", 1000)
+
+// Serves the file, dropping the connection midway through the first download unless resumable is false
+func filesServer(t *testing.T, resumable bool, ranges *[]string) *httptest.Server {
+	var (
+		mu      sync.Mutex
+		dropped bool
+	)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		disposition := `attachment; filename="../report.bin"`
+
+		if !dropped {
+			dropped = true
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nContent-Length: %d\r\n", len(fileContent))
+			fmt.Fprintf(buf, "Content-Disposition: %s\r\nETag: \"v1\"\r\n", disposition)
+			if resumable {
+				fmt.Fprint(buf, "Accept-Ranges: bytes\r\n")
+			}
+			fmt.Fprint(buf, "\r\n"+fileContent[:4000])
+			buf.Flush()
+
+			return
+		}
+
+		if r.Header.Get("Range") != "" {
+			assert.Equal(t, `"v1"`, r.Header.Get("If-Range"))
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", disposition)
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(fileContent))
+	}))
+}
+
+func TestIsBinaryOperation(t *testing.T) {
+	model, err := LoadV3([]byte(downloadSpec))
+	assert.NoError(t, err)
+
+	for path, binary := range map[string]bool{"/report": true, "/avatar": true, "/status": false} {
+		assert.Equal(t, binary, isBinaryOperation(model.Model.Paths.PathItems.GetOrZero(path).Get), path)
+	}
+}
+
+func TestDispositionFilename(t *testing.T) {
+	assert.Equal(t, "report.pdf", dispositionFilename(`attachment; filename="report.pdf"`))
+	assert.Equal(t, "passwd", dispositionFilename(`attachment; filename="../../etc/passwd"`))
+	assert.Equal(t, "passwd", dispositionFilename(`attachment; filename="..\\..\\passwd"`))
+	assert.Equal(t, "naïve.txt", dispositionFilename(`attachment; filename*=UTF-8''na%C3%AFve.txt`))
+	assert.Empty(t, dispositionFilename(`attachment; filename=".."`))
+	assert.Empty(t, dispositionFilename("inline"))
+	assert.Empty(t, dispositionFilename(""))
+}
+
+func TestDownload(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	send := func(server *httptest.Server, data HandlerData) (string, error) {
+		data.Method, data.Server, data.Path = "get", server.URL, "/report"
+		req, err := data.NewRequest(context.Background(), nil)
+		assert.NoError(t, err)
+
+		var out bytes.Buffer
+		err = data.Send(&out, req)
+
+		return out.String(), err
+	}
+
+	// Named after the Content-Disposition and resumed where the connection dropped
+	var ranges []string
+	server := filesServer(t, true, &ranges)
+	defer server.Close()
+
+	out, err := send(server, HandlerData{})
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+	assert.Equal(t, []string{"", "bytes=4000-"}, ranges)
+
+	written, err := os.ReadFile(filepath.Join(dir, "report.bin"))
+	assert.NoError(t, err)
+	assert.Equal(t, fileContent, string(written))
+
+	// The name chosen by the server doesn't replace a file unless forced
+	_, err = send(server, HandlerData{})
+	assert.EqualError(t, err, "File report.bin already exists, use --force to overwrite it")
+
+	_, err = send(server, HandlerData{Force: true})
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, "out", "copy.bin")
+	assert.NoError(t, os.Mkdir(filepath.Dir(path), 0o755))
+	_, err = send(server, HandlerData{OutputFile: path})
+	assert.NoError(t, err)
+	written, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, fileContent, string(written))
+
+	out, err = send(server, HandlerData{OutputFile: "-"})
+	assert.NoError(t, err)
+	assert.Equal(t, fileContent, out)
+
+	// Without ranges, a dropped download fails and is removed
+	ranges = nil
+	server = filesServer(t, false, &ranges)
+	defer server.Close()
+
+	_, err = send(server, HandlerData{OutputFile: path})
+	assert.ErrorContains(t, err, "unexpected EOF")
+	assert.NoFileExists(t, path)
+	assert.Equal(t, []string{""}, ranges)
+
+	// A download that can't be resumed now is kept for later
+	ranges = nil
+	server = filesServer(t, true, &ranges)
+	server.Config.Handler = dropping(server.Config.Handler)
+	defer server.Close()
+
+	_, err = send(server, HandlerData{OutputFile: path})
+	assert.ErrorContains(t, err, ", the 3.9 KiB downloaded are kept in "+path)
+	written, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, fileContent[:4000], string(written))
+	assert.Equal(t, []string{""}, ranges)
+}
+
+func TestDownloadWrongRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.bin")
+
+	var ranges []string
+	server := filesServer(t, true, &ranges)
+	defer server.Close()
+	serve := server.Config.Handler
+	// Answers the resuming requests from the start
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" {
+			serve.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(fileContent)-1, len(fileContent)))
+		w.WriteHeader(http.StatusPartialContent)
+		fmt.Fprint(w, fileContent)
+	})
+
+	data := HandlerData{Method: "get", Server: server.URL, Path: "/report", OutputFile: path}
+	req, err := data.NewRequest(context.Background(), nil)
+	assert.NoError(t, err)
+
+	err = data.Send(&bytes.Buffer{}, req)
+	assert.EqualError(t, err, "Cannot resume the download at byte 4000, the server sent the range bytes 0-9999/10000")
+	assert.NoFileExists(t, path)
+}
+
+func TestContentRangeStart(t *testing.T) {
+	for header, expected := range map[string]int64{"bytes 4000-9999/10000": 4000, "bytes 0-1/*": 0, "bytes */10000": -1, "items 1-2/3": -1, "": -1} {
+		start, ok := contentRangeStart(header)
+		if expected < 0 {
+			assert.False(t, ok, header)
+			continue
+		}
+
+		assert.True(t, ok, header)
+		assert.Equal(t, expected, start, header)
+	}
+}
+
+// Drops the connection of the requests resuming a download
+func dropping(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	})
+}
+
+func TestDownloadToTerminal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The null device isn't a character device")
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	defer devNull.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte{0, 1, 2})
+	}))
+	defer server.Close()
+
+	data := HandlerData{Method: "get", Server: server.URL, Path: "/blob"}
+	req, err := data.NewRequest(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.EqualError(t, data.Send(devNull, req), "Refusing to write binary to the terminal, use --output-file or --force")
+
+	data.Force = true
+	assert.NoError(t, data.Send(devNull, req))
+}
+
+func TestProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{w: &out, name: "big.iso", total: 3 << 20}
+
+	p.Write(make([]byte, 1<<20))
+	p.Write(make([]byte, 1<<19))
+	p.finish()
+	assert.Equal(t, "\rDownloading big.iso: 1.0 MiB / 3.0 MiB (33%)\rDownloading big.iso: 1.5 MiB / 3.0 MiB (50%)\n", out.String())
+
+	out.Reset()
+	p = &progress{w: &out, name: "stream", total: -1}
+	p.Write(make([]byte, 512))
+	assert.Equal(t, "\rDownloading stream: 512 B", out.String())
+
+	assert.Equal(t, "1.0 KiB", byteSize(1024))
+	assert.Equal(t, "2.5 GiB", byteSize(5<<29))
+}
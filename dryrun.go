@@ -0,0 +1,270 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	dryRunFlag  = "dry-run"
+	dryRunUsage = "Print the request instead of sending it, with the secrets masked"
+	curlFlag    = "curl"
+	curlUsage   = "Print the request as a curl command instead of sending it, with the secrets masked"
+	masked      = "***"
+)
+
+// Returned by Do instead of sending the request with --dry-run or --curl, the command then exits cleanly
+var ErrDryRun = errors.New("Dry run, the request wasn't sent")
+
+// The headers always carrying secrets, the scheme of their values is kept
+var secretHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// The secrets of the operation along with the API keys of its credentials
+func (h HandlerData) allSecrets() []secret {
+	secrets := h.secrets
+	for _, cred := range h.Credentials {
+		if cred.Type == APIKey {
+			secrets = append(secrets, secret{in: cred.In, name: cred.Name})
+		}
+	}
+
+	return secrets
+}
+
+// A copy of an authorized request with the values of its secrets masked
+func (h HandlerData) mask(req *http.Request) *http.Request {
+	req = req.Clone(req.Context())
+	h.maskHeader(req.Header)
+
+	for _, s := range h.allSecrets() {
+		switch s.in {
+		case "query":
+			if query := req.URL.Query(); query.Has(s.name) {
+				query.Set(s.name, masked)
+				req.URL.RawQuery = strings.ReplaceAll(query.Encode(), url.QueryEscape(masked), masked)
+			}
+		case "cookie":
+			cookies := req.Cookies()
+			req.Header.Del("Cookie")
+			for _, cookie := range cookies {
+				if cookie.Name == s.name {
+					cookie.Value = masked
+				}
+				req.AddCookie(cookie)
+			}
+		}
+	}
+
+	return req
+}
+
+// Masks the values of the secret headers and cookies being set of a request or a response in place
+func (h HandlerData) maskHeader(header http.Header) {
+	for _, name := range secretHeaders {
+		if value := header.Get(name); value != "" {
+			if scheme, _, ok := strings.Cut(value, " "); ok {
+				header.Set(name, scheme+" "+masked)
+			} else {
+				header.Set(name, masked)
+			}
+		}
+	}
+
+	for _, s := range h.allSecrets() {
+		switch s.in {
+		case "header":
+			if header.Get(s.name) != "" {
+				header.Set(s.name, masked)
+			}
+		case "cookie":
+			for i, value := range header["Set-Cookie"] {
+				if name, _, _ := strings.Cut(value, "="); name == s.name {
+					header["Set-Cookie"][i] = name + "=" + masked
+				}
+			}
+		}
+	}
+}
+
+// Masks the values of the secret properties of a JSON body, false when there are some and it isn't JSON
+func (h HandlerData) maskBody(body []byte) ([]byte, bool) {
+	var names []string
+	for _, s := range h.allSecrets() {
+		if s.in == "body" {
+			names = append(names, s.name)
+		}
+	}
+	if len(names) == 0 {
+		return body, true
+	}
+
+	var value any
+	if err := decodeJSON(body, &value); err != nil {
+		return nil, false
+	}
+	if !maskProperties(value, names) {
+		return body, true
+	}
+
+	body, err := json.Marshal(value)
+
+	return body, err == nil
+}
+
+// Masks the properties with these names anywhere in a decoded JSON value, whether there were any
+func maskProperties(value any, names []string) bool {
+	found := false
+
+	switch v := value.(type) {
+	case map[string]any:
+		for key, val := range v {
+			if slices.Contains(names, key) {
+				v[key], found = masked, true
+				continue
+			}
+			found = maskProperties(val, names) || found
+		}
+	case []any:
+		for _, item := range v {
+			found = maskProperties(item, names) || found
+		}
+	}
+
+	return found
+}
+
+// A body as it's printed: text with its secrets masked, or a note on why it isn't shown
+func (h HandlerData) printableBody(body []byte) string {
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("(%d bytes of binary data)", len(body))
+	}
+
+	body, ok := h.maskBody(body)
+	if !ok {
+		return "(body not shown, its secrets can't be masked)"
+	}
+
+	return strings.TrimSuffix(string(body), "\n")
+}
+
+// Reads the body of a request about to be printed, from GetBody when possible to leave it unread
+func printedBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body := req.Body
+	if req.GetBody != nil {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+		defer body.Close()
+	}
+
+	return io.ReadAll(body)
+}
+
+// Prints the authorized request as set by DryRun or Curl instead of sending it, returning ErrDryRun once printed
+func (h HandlerData) printRequest(req *http.Request) error {
+	body, err := printedBody(req)
+	if err != nil {
+		return err
+	}
+
+	w := h.out
+	if w == nil {
+		w = os.Stdout
+	}
+
+	req = h.mask(req)
+	if h.Curl {
+		err = h.writeCurl(w, req, body)
+	} else {
+		err = h.writeRequest(w, req, body)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ErrDryRun
+}
+
+// The header lines of a request sorted by name, eg Accept: application/json
+func headerLines(header http.Header) []string {
+	var lines []string
+	for name, values := range header {
+		for _, value := range values {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	slices.Sort(lines)
+
+	return lines
+}
+
+// Writes the request line, the headers and the body like they're sent
+func (h HandlerData) writeRequest(w io.Writer, req *http.Request, body []byte) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", req.Method, req.URL)
+	for _, line := range headerLines(req.Header) {
+		b.WriteString(line + "\n")
+	}
+
+	if len(body) > 0 {
+		b.WriteString("\n" + h.printableBody(body) + "\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// Writes a curl command sending the request, the bodies not shown are read from stdin
+func (h HandlerData) writeCurl(w io.Writer, req *http.Request, body []byte) error {
+	command := "curl"
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodHead:
+		command += " --head"
+	default:
+		command += " -X " + req.Method
+	}
+	args := []string{command + " " + shellQuote(req.URL.String())}
+
+	for _, line := range headerLines(req.Header) {
+		args = append(args, "-H "+shellQuote(line))
+	}
+
+	if len(body) > 0 {
+		if text, ok := h.maskBody(body); ok && utf8.Valid(body) {
+			args = append(args, "--data-binary "+shellQuote(string(text)))
+		} else {
+			args = append(args, "--data-binary @-")
+		}
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(args, " \\\n  "))
+
+	return err
+}
+
+// Quotes a string for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
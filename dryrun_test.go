@@ -0,0 +1,120 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const dryRunSpec = `
+openapi: "3.0.0"
+info:
+  title: Items
+  version: "0.1.0"
+servers:
+  - url: %s
+security:
+  - bearerAuth: []
+paths:
+  "/items/{id}":
+    put:
+      operationId: PutItem
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: string
+        - name: dry
+          in: query
+          schema:
+            type: boolean
+      responses:
+        "200":
+          description: The item
+components:
+  securitySchemes:
+    bearerAuth:
+      type: http
+      scheme: bearer
+`
+
+func TestDryRun(t *testing.T) {
+	values := map[string]string{"limit": "10"}
+	data := HandlerData{
+		Method:      "post",
+		Server:      "https://api.example.com",
+		Path:        "/items",
+		QueryParams: []ParamMeta{{Name: "limit", Type: Integer}},
+		Credentials: []Credential{
+			{Type: APIKey, In: "query", Name: "key", Value: "k1"},
+			{Type: APIKey, In: "header", Name: "X-API-Key", Value: "k2"},
+			{Type: APIKey, In: "cookie", Name: "session", Value: "k3"},
+			{Type: Bearer, Value: "t1"},
+		},
+		DryRun: true,
+		param: func(name string) (string, bool) {
+			value, ok := values[name]
+			return value, ok
+		},
+	}
+
+	var out bytes.Buffer
+	data.out = &out
+
+	send := func(body string) error {
+		req, err := data.NewRequest(context.Background(), strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+
+		_, err = data.Do(req)
+
+		// The body is left to be sent
+		if body != "" {
+			sent, _ := printedBody(req)
+			assert.Equal(t, body, string(sent))
+		}
+
+		return err
+	}
+
+	assert.ErrorIs(t, send(`{"name":"it's"}`), ErrDryRun)
+	assert.Equal(t, `POST https://api.example.com/items?key=***&limit=10
+Authorization: Bearer ***
+Content-Type: application/json
+Cookie: theme=dark; session=***
+X-Api-Key: ***
+
+{"name":"it's"}
+`, out.String())
+
+	out.Reset()
+	data.DryRun, data.Curl = false, true
+	assert.ErrorIs(t, send(`{"name":"it's"}`), ErrDryRun)
+	assert.Equal(t, `curl -X POST 'https://api.example.com/items?key=***&limit=10' \
+  -H 'Authorization: Bearer ***' \
+  -H 'Content-Type: application/json' \
+  -H 'Cookie: theme=dark; session=***' \
+  -H 'X-Api-Key: ***' \
+  --data-binary '{"name":"it'\''s"}'
+`, out.String())
+
+	out.Reset()
+	data.Method, data.Credentials = "get", []Credential{{Type: Basic, Value: "me:secret"}}
+	assert.ErrorIs(t, send(""), ErrDryRun)
+	assert.Equal(t, `curl 'https://api.example.com/items?limit=10' \
+  -H 'Authorization: Basic ***' \
+  -H 'Content-Type: application/json' \
+  -H 'Cookie: theme=dark'
+`, out.String())
+
+	out.Reset()
+	data.Method, data.Curl, data.DryRun = "put", false, true
+	assert.ErrorIs(t, send("\x00\xff"), ErrDryRun)
+	assert.Contains(t, out.String(), "\n\n(2 bytes of binary data)\n")
+}
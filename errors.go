@@ -0,0 +1,339 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+// An error response of the server
+type HTTPError struct {
+	Status  int
+	Header  http.Header
+	Body    any      // decoded by the content type like Render does, nil when empty
+	Problem *Problem // the human readable parts of the body if any
+}
+
+// The human readable parts of an RFC 7807 problem or of a body in an error schema declared in the spec
+type Problem struct {
+	Type   string
+	Title  string
+	Detail string
+	Errors []FieldError
+}
+
+// An error about a field of the request, eg from the invalid-params of a problem
+type FieldError struct {
+	Field   string // the name, path or JSON pointer of the field
+	Message string
+}
+
+// The keys of the human readable parts, the first one found is used
+var (
+	titleKeys        = []string{"title", "message", "error", "msg"}
+	detailKeys       = []string{"detail", "error_description", "description", "reason"}
+	fieldErrorsKeys  = []string{"errors", "invalid-params", "invalid_params", "violations", "details"}
+	fieldKeys        = []string{"field", "name", "pointer", "path", "param"}
+	fieldMessageKeys = []string{"message", "detail", "reason", "description", "msg"}
+)
+
+// Reads and decodes the body of an error response, an RFC 7807 problem is parsed into the Problem
+func NewHTTPError(resp *http.Response) *HTTPError {
+	return newHTTPError(resp, nil)
+}
+
+// Parses the Problem also from the error schema of the responses for the status, if declared
+func newHTTPError(resp *http.Response, responses *v3.Responses) *HTTPError {
+	e := &HTTPError{Status: resp.StatusCode, Header: resp.Header}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return e
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if _, e.Body, err = decodeResponse(resp); err != nil {
+		e.Body = string(data)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/problem+json" {
+		e.Problem = problemOf(e.Body, nil)
+	} else if schema := errorSchema(responses, resp.StatusCode, mediaType); schema != nil {
+		e.Problem = problemOf(e.Body, schema)
+	}
+
+	return e
+}
+
+// The object schema declared for the status and media type of an error response
+func errorSchema(responses *v3.Responses, status int, mediaType string) *base.Schema {
+	if responses == nil {
+		return nil
+	}
+
+	spec, _ := matchResponse(responses, status)
+	if spec == nil || spec.Content == nil {
+		return nil
+	}
+
+	media, _ := matchMediaType(spec, mediaType)
+	if media == nil || media.Schema == nil {
+		return nil
+	}
+
+	schema := media.Schema.Schema()
+	if schema == nil || schema.Properties == nil {
+		return nil
+	}
+
+	return schema
+}
+
+// Picks the human readable parts of a body, only the properties of the schema if set
+func problemOf(body any, schema *base.Schema) *Problem {
+	obj, ok := body.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	declared := schema != nil
+	pick := func(obj map[string]any, keys []string, declared bool) any {
+		for _, key := range keys {
+			if declared {
+				if _, ok := schema.Properties.Get(key); !ok {
+					continue
+				}
+			}
+
+			if value, ok := obj[key]; ok && value != nil {
+				return value
+			}
+		}
+
+		return nil
+	}
+	str := func(value any) string {
+		if s, ok := value.(string); ok {
+			return s
+		}
+
+		return ""
+	}
+
+	p := &Problem{
+		Type:   str(obj["type"]),
+		Title:  str(pick(obj, titleKeys, declared)),
+		Detail: str(pick(obj, detailKeys, declared)),
+	}
+
+	items, _ := pick(obj, fieldErrorsKeys, declared).([]any)
+	for _, item := range items {
+		fe, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		field, message := str(pick(fe, fieldKeys, false)), str(pick(fe, fieldMessageKeys, false))
+		if field != "" || message != "" {
+			p.Errors = append(p.Errors, FieldError{Field: field, Message: message})
+		}
+	}
+
+	if p.Title == "" && p.Detail == "" && len(p.Errors) == 0 {
+		return nil
+	}
+
+	return p
+}
+
+// The title and detail, then an indented line per field error
+func (p *Problem) String() string {
+	var parts []string
+	for _, part := range []string{p.Title, p.Detail} {
+		if part != "" && !slices.Contains(parts, part) {
+			parts = append(parts, part)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(parts, ": "))
+	for _, fe := range p.Errors {
+		b.WriteString("\n  ")
+		switch {
+		case fe.Field == "":
+			b.WriteString(fe.Message)
+		case fe.Message == "":
+			b.WriteString(fe.Field)
+		default:
+			b.WriteString(fe.Field + ": " + fe.Message)
+		}
+	}
+
+	return b.String()
+}
+
+// Returns an *HTTPError for 4xx and 5xx responses, nil otherwise
+func CheckResponse(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	return NewHTTPError(resp)
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("HTTP %d %s", e.Status, http.StatusText(e.Status))
+
+	if e.Problem != nil {
+		// Field errors alone start on the next line
+		problem := e.Problem.String()
+		if !strings.HasPrefix(problem, "\n") {
+			problem = ": " + problem
+		}
+
+		return msg + problem
+	}
+
+	switch body := e.Body.(type) {
+	case nil:
+		return msg
+	case string:
+		return msg + ": " + body
+	default:
+		return msg + ": " + compactJSON(body)
+	}
+}
+
+// Maps the errors returned by handlers to the exit codes of the process, 0 leaves them unmapped
+type ExitCodes struct {
+	ClientError int         // for 4xx responses
+	ServerError int         // for 5xx responses
+	Transport   int         // when no response was received, eg the network is down
+	Statuses    map[int]int // for specific statuses, eg 404, over the ones above
+	Interrupted int         // when the command was interrupted, eg with Ctrl-C
+	Timeout     int         // when the command ran out of its --timeout
+	WaitFailure int         // when a waiter reached a failure state
+}
+
+// Used unless set via WithExitCodes, 130 and 124 are the codes of shells and timeout(1)
+var DefaultExitCodes = ExitCodes{ClientError: 2, ServerError: 3, Transport: 4, WaitFailure: 5, Interrupted: 130, Timeout: 124}
+
+func (c ExitCodes) of(err error) int {
+	// Before the others, as the requests cut short by them fail too
+	switch {
+	case errors.Is(err, context.Canceled):
+		return c.Interrupted
+	case errors.Is(err, context.DeadlineExceeded):
+		return c.Timeout
+	}
+
+	var waitErr *WaitError
+	if errors.As(err, &waitErr) {
+		return c.WaitFailure
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		if code, ok := c.Statuses[httpErr.Status]; ok {
+			return code
+		}
+
+		switch {
+		case httpErr.Status >= 500:
+			return c.ServerError
+		case httpErr.Status >= 400:
+			return c.ClientError
+		}
+
+		return 0
+	}
+
+	// A URL that can't be parsed is no transport failure, nothing was sent. It's a net.Error too.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if urlErr.Op == "parse" {
+			return 0
+		}
+
+		return c.Transport
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return c.Transport
+	}
+
+	return 0
+}
+
+// An error of a handler with the exit code of the process, it's a cli.ExitCoder for urfave/cli
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// The exit code for an error returned by running the root command: 0 for nil, the mapped code or 1.
+// With cobra, use it as: os.Exit(climate.ExitCode(rootCmd.Execute()))
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	return 1
+}
+
+// Wraps the error of a handler with its exit code if mapped
+func (c ExitCodes) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+
+	if code := c.of(err); code != 0 {
+		return &ExitError{Code: code, Err: err}
+	}
+
+	return err
+}
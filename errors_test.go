@@ -0,0 +1,166 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPError(t *testing.T) {
+	response := func(status int, contentType, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	assert.NoError(t, CheckResponse(response(204, "", "")))
+
+	err := CheckResponse(response(404, "application/json", `{"message": "no such item"}`))
+	var httpErr *HTTPError
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, 404, httpErr.Status)
+	assert.Equal(t, map[string]any{"message": "no such item"}, httpErr.Body)
+	assert.EqualError(t, err, `HTTP 404 Not Found: {"message":"no such item"}`)
+
+	assert.EqualError(t, NewHTTPError(response(502, "text/plain", "bad gateway")), "HTTP 502 Bad Gateway: bad gateway")
+	assert.EqualError(t, NewHTTPError(response(500, "application/json", "{")), "HTTP 500 Internal Server Error: {")
+	assert.EqualError(t, NewHTTPError(response(503, "", "")), "HTTP 503 Service Unavailable")
+
+	// Rendering an error response returns it
+	assert.ErrorAs(t, HandlerData{Output: JSON}.Render(io.Discard, response(400, "application/json", "{}")), &httpErr)
+}
+
+func TestExitCodes(t *testing.T) {
+	transport := &url.Error{Op: "Get", URL: "http://localhost", Err: errors.New("connection refused")}
+	_, parseErr := url.Parse("http://[::1")
+	codes := ExitCodes{ClientError: 2, ServerError: 3, Transport: 4, Statuses: map[int]int{404: 5}, Interrupted: 130, Timeout: 124}
+
+	for err, code := range map[error]int{
+		&HTTPError{Status: 400}:              2,
+		&HTTPError{Status: 404}:              5,
+		&HTTPError{Status: 503}:              3,
+		&HTTPError{Status: 302}:              1,
+		fmt.Errorf("Listing: %w", transport): 4,
+		fmt.Errorf("Listing: %w", parseErr):  1,
+		&url.Error{Op: "Get", URL: "http://localhost", Err: context.Canceled}:         130,
+		&url.Error{Op: "Get", URL: "http://localhost", Err: context.DeadlineExceeded}: 124,
+		&TimeoutError{Timeout: time.Second}:                                           124,
+		errors.New("Something else"):                                                  1,
+		&ExitError{Code: 7, Err: errors.New("x")}:                                     7,
+	} {
+		assert.Equal(t, code, ExitCode(codes.wrap(err)), err.Error())
+	}
+
+	assert.Equal(t, 0, ExitCode(codes.wrap(nil)))
+
+	err := codes.wrap(&HTTPError{Status: 400})
+	var exitErr *ExitError
+	assert.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 2, exitErr.ExitCode())
+	assert.EqualError(t, err, "HTTP 400 Bad Request")
+
+	// Unset codes leave the errors as they are
+	err = errors.New("Something else")
+	assert.Equal(t, err, ExitCodes{}.wrap(err))
+	assert.Equal(t, 1, ExitCode(ExitCodes{}.wrap(&HTTPError{Status: 500})))
+}
+
+const errorsSpec = `
+openapi: "3.0.0"
+info:
+  title: Errors
+  version: "0.1.0"
+paths:
+  "/items":
+    post:
+      operationId: AddItem
+      responses:
+        "201":
+          description: Added
+        "422":
+          description: Invalid
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  message:
+                    type: string
+                  violations:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        field:
+                          type: string
+                        reason:
+                          type: string
+`
+
+func TestProblem(t *testing.T) {
+	model, err := LoadV3([]byte(errorsSpec))
+	assert.NoError(t, err)
+
+	responses := model.Model.Paths.PathItems.GetOrZero("/items").Post.Responses
+	response := func(status int, contentType, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {contentType}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	problem := `{
+		"type": "https://example.com/probs/invalid",
+		"title": "Your request is not valid",
+		"detail": "2 params are invalid",
+		"invalid-params": [{"name": "age", "reason": "must be positive"}, {"name": "color"}]
+	}`
+	e := newHTTPError(response(400, "application/problem+json", problem), nil)
+	assert.Equal(
+		t,
+		&Problem{
+			Type:   "https://example.com/probs/invalid",
+			Title:  "Your request is not valid",
+			Detail: "2 params are invalid",
+			Errors: []FieldError{{Field: "age", Message: "must be positive"}, {Field: "color"}},
+		},
+		e.Problem,
+	)
+	assert.EqualError(t, e, "HTTP 400 Bad Request: Your request is not valid: 2 params are invalid\n  age: must be positive\n  color")
+
+	e = newHTTPError(response(404, "application/problem+json", `{"title": "Not Found", "detail": "Not Found"}`), nil)
+	assert.EqualError(t, e, "HTTP 404 Not Found: Not Found")
+
+	// Only the declared properties of the error schema are used
+	body := `{"message": "Invalid item", "description": "undeclared", "violations": [{"field": "/name", "reason": "is empty"}]}`
+	e = newHTTPError(response(422, "application/json", body), responses)
+	assert.Equal(t, &Problem{Title: "Invalid item", Errors: []FieldError{{Field: "/name", Message: "is empty"}}}, e.Problem)
+	assert.Equal(t, "HTTP 422 Unprocessable Entity\n  /name: is empty", (&HTTPError{Status: 422, Problem: &Problem{Errors: e.Problem.Errors}}).Error())
+
+	// Without a declared schema the body is shown as is
+	e = newHTTPError(response(422, "application/json", body), nil)
+	assert.Nil(t, e.Problem)
+	assert.Nil(t, newHTTPError(response(500, "application/problem+json", `["oops"]`), nil).Problem)
+
+	// The full payload is rendered only when JSON output is asked for
+	var out bytes.Buffer
+	data := HandlerData{Output: JSON, responses: responses}
+	assert.EqualError(t, data.Render(&out, response(422, "application/json", body)), "HTTP 422 Unprocessable Entity: Invalid item\n  /name: is empty")
+	assert.Empty(t, out.String())
+
+	data.outputSet = true
+	assert.Error(t, data.Render(&out, response(422, "application/json", body)))
+	assert.Contains(t, out.String(), `"description":"undeclared"`)
+}
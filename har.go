@@ -0,0 +1,483 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	recordFlag   = "record"
+	recordUsage  = "Record the requests and responses to this HAR file, adding to it if it exists"
+	replayFlag   = "replay"
+	replayUsage  = "Answer the requests with the responses recorded in this HAR file instead of sending them"
+	harVersion   = "1.2"
+	harBodyLimit = 1 << 20 // the bytes of a response body recorded at most, so that streams and big exports aren't kept in memory
+)
+
+// The subset of HTTP Archive 1.2 recorded and replayed: http://www.softwareishard.com/blog/har-12-spec
+type har struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // in milliseconds, like the timings
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"` // base64 for binary bodies, not in the spec but common
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"` // why the text isn't the whole body
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// A HAR file the requests of a command are recorded to or replayed from
+type archive struct {
+	path   string
+	replay bool
+
+	mu       sync.Mutex
+	har      har
+	added    bool         // whether there are entries to write
+	replayed map[int]bool // the entries already answered with
+}
+
+// Opens the archive set by --record or --replay, nil if neither is
+func openArchive(record, replay, rootName string) (*archive, error) {
+	switch {
+	case record != "" && replay != "":
+		return nil, errors.New("Use either --record or --replay, not both")
+	case replay != "":
+		a := &archive{path: replay, replay: true, replayed: make(map[int]bool)}
+		data, err := os.ReadFile(replay)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read the HAR file %s: %w", replay, err)
+		}
+		if err := json.Unmarshal(data, &a.har); err != nil {
+			return nil, fmt.Errorf("Invalid HAR file %s: %w", replay, err)
+		}
+
+		return a, nil
+	case record != "":
+		a := &archive{path: record}
+		data, err := os.ReadFile(record)
+		if errors.Is(err, fs.ErrNotExist) {
+			a.har.Log = harLog{Version: harVersion, Creator: harCreator{Name: rootName, Version: buildVersion()}}
+			return a, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read the HAR file %s: %w", record, err)
+		}
+		if err := json.Unmarshal(data, &a.har); err != nil {
+			return nil, fmt.Errorf("Invalid HAR file %s: %w", record, err)
+		}
+
+		return a, nil
+	}
+
+	return nil, nil
+}
+
+// The version of the main module of the CLI, (devel) when it's not a released one
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+
+	return ""
+}
+
+// Records or replays the requests sent through it, with their secrets masked
+type archiveTransport struct {
+	next http.RoundTripper
+	a    *archive
+	h    HandlerData
+}
+
+// A copy of the client recording to or replaying from the archive
+func (h HandlerData) archived(client *http.Client) *http.Client {
+	t := &archiveTransport{next: client.Transport, a: h.archive, h: h}
+	if t.next == nil {
+		t.next = http.DefaultTransport
+	}
+
+	archived := *client
+	archived.Transport = t
+
+	return &archived
+}
+
+func (t *archiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := t.h.harRequest(req, body)
+
+	if t.a.replay {
+		return t.a.answer(req, recorded)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	wait := time.Since(start)
+	limit := harBodyLimit
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); !recordedMediaType(mediaType) {
+		limit = 0
+	}
+
+	resp.Body = &recordingBody{ReadCloser: resp.Body, limit: limit, done: func(content []byte, size int) {
+		t.a.add(harEntry{
+			StartedDateTime: start,
+			Time:            milliseconds(time.Since(start)),
+			Request:         recorded,
+			Response:        t.h.harResponse(resp, content, size),
+			Timings:         harTimings{Wait: milliseconds(wait), Receive: milliseconds(time.Since(start) - wait)},
+		})
+	}}
+
+	return resp, nil
+}
+
+// A request as it's recorded, with its secrets masked
+func (h HandlerData) harRequest(req *http.Request, body []byte) harRequest {
+	shown := h.mask(req)
+	recorded := harRequest{
+		Method:      shown.Method,
+		URL:         shown.URL.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(shown.Header),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	query := shown.URL.Query()
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		for _, value := range query[name] {
+			recorded.QueryString = append(recorded.QueryString, harNameValue{Name: name, Value: value})
+		}
+	}
+
+	if len(body) > 0 {
+		text, encoding := h.harBody(body)
+		recorded.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: text, Encoding: encoding}
+	}
+
+	return recorded
+}
+
+// A response as it's recorded, with its secrets masked. The content is the first bytes of a body of size.
+func (h HandlerData) harResponse(resp *http.Response, content []byte, size int) harResponse {
+	header := resp.Header.Clone()
+	h.maskHeader(header)
+
+	var comment string
+	switch {
+	case size > 0 && len(content) == 0:
+		comment = fmt.Sprintf("The %d bytes of the body aren't recorded", size)
+	case size > len(content):
+		content = textPrefix(content)
+		comment = fmt.Sprintf("Truncated to the first %d of %d bytes", len(content), size)
+	}
+	text, encoding := h.harBody(content)
+
+	return harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)+" "),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(header),
+		Content: harContent{
+			Size:     size,
+			MimeType: resp.Header.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+			Comment:  comment,
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+}
+
+// The response bodies recorded: text, JSON and streams of them. Binary ones like downloads aren't.
+func recordedMediaType(mediaType string) bool {
+	return tracedMediaType(mediaType) || slices.Contains(streamingMediaTypes, mediaType)
+}
+
+// A body as it's recorded: text with its secrets masked, base64 if binary
+func (h HandlerData) harBody(body []byte) (string, string) {
+	if !utf8.Valid(body) {
+		return base64.StdEncoding.EncodeToString(body), "base64"
+	}
+
+	masked, ok := h.maskBody(body)
+	if !ok {
+		return "", ""
+	}
+
+	return string(masked), ""
+}
+
+// The header lines of a request or a response sorted by name
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for _, line := range headerLines(header) {
+		name, value, _ := strings.Cut(line, ": ")
+		headers = append(headers, harNameValue{Name: name, Value: value})
+	}
+
+	return headers
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Adds an entry, written with the others once the command is done
+func (a *archive) add(entry harEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.har.Log.Entries = append(a.har.Log.Entries, entry)
+	a.added = true
+}
+
+// Writes the entries recorded by a command once it's done or interrupted, warning if it can't.
+// The file is replaced only once the new one is complete.
+func (a *archive) flush() {
+	if a == nil || a.replay {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.added {
+		return
+	}
+
+	if err := a.write(); err != nil {
+		slog.Warn("Cannot write the HAR file", "path", a.path, "error", err)
+		return
+	}
+	a.added = false
+}
+
+func (a *archive) write() error {
+	data, err := json.MarshalIndent(a.har, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), ".*.har")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(append(data, '\n')); err == nil {
+		err = tmp.Chmod(0o644)
+	}
+	if err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), a.path)
+}
+
+// The response recorded for a request matched by its method, URL and body.
+// The same request is answered by the entries recorded for it in order, the last one repeated once they run out.
+func (a *archive) answer(req *http.Request, recorded harRequest) (*http.Response, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	match := -1
+	for i, entry := range a.har.Log.Entries {
+		if !sameRequest(entry.Request, recorded) {
+			continue
+		}
+
+		match = i
+		if !a.replayed[i] {
+			break
+		}
+	}
+	if match == -1 {
+		return nil, fmt.Errorf("No response recorded in %s for %s %s", a.path, recorded.Method, recorded.URL)
+	}
+	a.replayed[match] = true
+
+	recordedResp := a.har.Log.Entries[match].Response
+	content := []byte(recordedResp.Content.Text)
+	if recordedResp.Content.Encoding == "base64" {
+		var err error
+		if content, err = base64.StdEncoding.DecodeString(recordedResp.Content.Text); err != nil {
+			return nil, fmt.Errorf("Invalid content recorded in %s for %s %s: %w", a.path, recorded.Method, recorded.URL, err)
+		}
+	}
+
+	header := make(http.Header)
+	for _, line := range recordedResp.Headers {
+		header.Add(line.Name, line.Value)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recordedResp.Status, recordedResp.StatusText),
+		StatusCode:    recordedResp.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(content)),
+		ContentLength: int64(len(content)),
+		Request:       req,
+	}, nil
+}
+
+func sameRequest(a, b harRequest) bool {
+	var aBody, bBody string
+	if a.PostData != nil {
+		aBody = a.PostData.Text
+	}
+	if b.PostData != nil {
+		bBody = b.PostData.Text
+	}
+
+	return strings.EqualFold(a.Method, b.Method) && a.URL == b.URL && aBody == bBody
+}
+
+// A response body recording up to limit bytes of what's read of it, once it's read to the end or closed
+type recordingBody struct {
+	io.ReadCloser
+	content bytes.Buffer
+	limit   int
+	size    int // of what's read, recorded or not
+	done    func(content []byte, size int)
+	once    sync.Once
+}
+
+func (r *recordingBody) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.size += n
+	if kept := min(n, r.limit-r.content.Len()); kept > 0 {
+		r.content.Write(p[:kept])
+	}
+	if err == io.EOF {
+		r.record()
+	}
+
+	return n, err
+}
+
+func (r *recordingBody) Close() error {
+	r.record()
+
+	return r.ReadCloser.Close()
+}
+
+func (r *recordingBody) record() {
+	r.once.Do(func() { r.done(r.content.Bytes(), r.size) })
+}
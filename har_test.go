@@ -0,0 +1,224 @@
+package climate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := openArchive("", "", "accounts")
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+
+	_, err = openArchive("a.har", "b.har", "accounts")
+	assert.EqualError(t, err, "Use either --record or --replay, not both")
+
+	_, err = openArchive("", filepath.Join(dir, "missing.har"), "accounts")
+	assert.ErrorContains(t, err, "Cannot read the HAR file ")
+
+	invalid := filepath.Join(dir, "invalid.har")
+	assert.NoError(t, os.WriteFile(invalid, []byte("{"), 0o644))
+	_, err = openArchive(invalid, "", "accounts")
+	assert.ErrorContains(t, err, "Invalid HAR file "+invalid)
+
+	a, err = openArchive(filepath.Join(dir, "new.har"), "", "accounts")
+	assert.NoError(t, err)
+	assert.Equal(t, harVersion, a.har.Log.Version)
+	assert.Equal(t, "accounts", a.har.Log.Creator.Name)
+	assert.NoFileExists(t, filepath.Join(dir, "new.har"))
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.har")
+	server := accountsServer(t)
+
+	model, err := LoadV3(fmt.Appendf(nil, traceSpec, server.URL))
+	assert.NoError(t, err)
+
+	data := HandlerData{
+		Method:      "post",
+		Server:      server.URL,
+		Path:        "/accounts",
+		Credentials: []Credential{{Type: APIKey, In: "header", Name: "X-API-Key", Value: "k1"}},
+		secrets:     secretsOf(&model.Model, model.Model.Paths.PathItems.GetOrZero("/accounts").Post),
+	}
+	send := func(data HandlerData, body string) (string, error) {
+		req, err := data.NewRequest(context.Background(), strings.NewReader(body))
+		assert.NoError(t, err)
+
+		resp, err := data.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		received, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+
+		return string(received), nil
+	}
+
+	// Each command adds to the archive
+	for _, body := range []string{`{"name":"a"}`, `{"name":"b","credentials":[{"secret":"p1"}]}`} {
+		data.archive, err = openArchive(path, "", "accounts")
+		assert.NoError(t, err)
+
+		received, err := send(data, body)
+		assert.NoError(t, err)
+		assert.Equal(t, body, received)
+		data.archive.flush()
+	}
+	server.Close()
+
+	recorded, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	for _, secret := range []string{"k1", "s1", "p1"} {
+		assert.NotContains(t, string(recorded), secret)
+	}
+
+	var archive har
+	assert.NoError(t, json.Unmarshal(recorded, &archive))
+	assert.Len(t, archive.Log.Entries, 2)
+
+	entry := archive.Log.Entries[1]
+	assert.Equal(t, "POST", entry.Request.Method)
+	assert.Equal(t, server.URL+"/accounts", entry.Request.URL)
+	assert.Equal(t, `{"credentials":[{"secret":"***"}],"name":"b"}`, entry.Request.PostData.Text)
+	assert.Contains(t, entry.Request.Headers, harNameValue{Name: "X-Api-Key", Value: "***"})
+	assert.Equal(t, 201, entry.Response.Status)
+	assert.Equal(t, "Created", entry.Response.StatusText)
+	assert.Equal(t, "application/json", entry.Response.Content.MimeType)
+	assert.Contains(t, entry.Response.Headers, harNameValue{Name: "Set-Cookie", Value: "session=***"})
+
+	// Replayed with the server gone, matched by the body with its secrets masked the same way
+	data.archive, err = openArchive("", path, "accounts")
+	assert.NoError(t, err)
+
+	received, err := send(data, `{"name":"a"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"a"}`, received)
+
+	received, err = send(data, `{"name":"b","credentials":[{"secret":"p2"}]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"credentials":[{"secret":"***"}],"name":"b"}`, received)
+
+	_, err = send(data, `{"name":"c"}`)
+	assert.ErrorContains(t, err, fmt.Sprintf("No response recorded in %s for POST %s/accounts", path, server.URL))
+}
+
+func TestRecordLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exports.har")
+	record := strings.Repeat(`{"id":"é"}`+"\n", harBodyLimit/10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/export":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			fmt.Fprint(w, record)
+		case "/download":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0, 1, 2, 3})
+		}
+	}))
+	defer server.Close()
+
+	archive, err := openArchive(path, "", "exports")
+	assert.NoError(t, err)
+
+	data := HandlerData{Method: "get", Server: server.URL, archive: archive}
+	for path, size := range map[string]int{"/export": len(record), "/download": 4} {
+		data.Path = path
+		req, err := data.NewRequest(context.Background(), nil)
+		assert.NoError(t, err)
+
+		resp, err := data.Do(req)
+		assert.NoError(t, err)
+
+		// The whole body is read, only a part of it is recorded
+		received, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Body.Close())
+		assert.Len(t, received, size)
+	}
+	archive.flush()
+
+	recorded, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	var har har
+	assert.NoError(t, json.Unmarshal(recorded, &har))
+
+	entries := map[string]harContent{}
+	for _, entry := range har.Log.Entries {
+		entries[strings.TrimPrefix(entry.Request.URL, server.URL)] = entry.Response.Content
+	}
+
+	export := entries["/export"]
+	assert.Equal(t, len(record), export.Size)
+	assert.True(t, strings.HasPrefix(record, export.Text))
+	assert.LessOrEqual(t, len(export.Text), harBodyLimit)
+	assert.Equal(t, fmt.Sprintf("Truncated to the first %d of %d bytes", len(export.Text), len(record)), export.Comment)
+
+	download := entries["/download"]
+	assert.Equal(t, 4, download.Size)
+	assert.Empty(t, download.Text)
+	assert.Equal(t, "The 4 bytes of the body aren't recorded", download.Comment)
+}
+
+func TestReplayInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "polls.har")
+
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"poll":%d}`, polls.Add(1))
+	}))
+
+	data := HandlerData{Method: "get", Server: server.URL, Path: "/status", Output: JSON}
+	poll := func() (string, error) {
+		req, err := data.NewRequest(context.Background(), nil)
+		assert.NoError(t, err)
+
+		var out strings.Builder
+		err = data.Send(&out, req)
+
+		return out.String(), err
+	}
+
+	archive, err := openArchive(path, "", "jobs")
+	assert.NoError(t, err)
+	data.archive = archive
+	for range 2 {
+		_, err := poll()
+		assert.NoError(t, err)
+	}
+	server.Close()
+
+	// Written once the command is done
+	assert.NoFileExists(t, path)
+	archive.flush()
+
+	archive, err = openArchive("", path, "jobs")
+	assert.NoError(t, err)
+	data.archive = archive
+
+	// The same request gets the next response recorded for it, the last one once they run out
+	for _, expected := range []string{`{"poll":1}`, `{"poll":2}`, `{"poll":2}`} {
+		out, err := poll()
+		assert.NoError(t, err)
+		assert.JSONEq(t, expected, out)
+	}
+}
@@ -0,0 +1,402 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"go.yaml.in/yaml/v4"
+)
+
+const (
+	loginCmd          = "login"
+	loginUsage        = "Log in and store the tokens for later commands"
+	schemeFlag        = "scheme"
+	schemeUsage       = "The security scheme to log in to, defaults to the first one supporting it"
+	noBrowserFlag     = "no-browser"
+	noBrowserUsage    = "Only print the authorization URL instead of opening the browser"
+	deviceFlag        = "device"
+	deviceUsage       = "Log in with a code on another device, eg when there's no browser"
+	logoutCmd         = "logout"
+	logoutUsage       = "Remove the stored credentials"
+	logoutSchemeUsage = "The security scheme to log out of, defaults to all of them"
+	authCmd           = "auth"
+	authUsage         = "Manage the stored credentials"
+	statusCmd         = "status"
+	statusUsage       = "Show the state of the credentials of each security scheme"
+	loginCallback     = "/callback"
+	loginSuccessMsg   = "Logged in, you can close this window now."
+)
+
+// Opens a URL in the user's browser
+var openBrowser = func(target string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+
+	return cmd.Start()
+}
+
+// The flows of an oauth2 scheme usable to log in
+type loginFlows struct {
+	authorizationCode *v3.OAuthFlow
+	device            *v3.OAuthFlow // the flow with the token endpoint to poll in the device grant
+	deviceURL         string        // the device authorization endpoint
+}
+
+func (f loginFlows) supported() bool {
+	return f.authorizationCode != nil || f.device != nil
+}
+
+// The flow to refresh the tokens from logging in with
+func (f loginFlows) tokenFlow() *v3.OAuthFlow {
+	if f.authorizationCode != nil {
+		return f.authorizationCode
+	}
+
+	return f.device
+}
+
+// Finds the flows to log in with.
+// The device grant is supported when the scheme or one of its flows sets x-cli-device-authorization-url.
+func loginFlowsOf(scheme *v3.SecurityScheme) loginFlows {
+	var lf loginFlows
+
+	if !isOAuth2(scheme) {
+		return lf
+	}
+
+	flows := scheme.Flows
+	lf.authorizationCode = flows.AuthorizationCode
+
+	candidates := []*v3.OAuthFlow{flows.Device, flows.AuthorizationCode, flows.ClientCredentials, flows.Password}
+	deviceURL := func(exts *orderedmap.Map[string, *yaml.Node]) string {
+		ex, err := parseExtensions(exts)
+		if err != nil {
+			slog.Warn("Cannot parse extensions of the security scheme", "error", err)
+
+			return ""
+		}
+
+		return ex.deviceAuthorizationURL
+	}
+
+	for _, flow := range candidates {
+		if flow != nil {
+			if url := deviceURL(flow.Extensions); url != "" {
+				lf.device, lf.deviceURL = flow, url
+
+				return lf
+			}
+		}
+	}
+
+	if url := deviceURL(scheme.Extensions); url != "" {
+		for _, flow := range candidates {
+			if flow != nil && flow.TokenUrl != "" {
+				lf.device, lf.deviceURL = flow, url
+
+				return lf
+			}
+		}
+	}
+
+	return lf
+}
+
+// The names of the oauth2 schemes supporting logging in
+func loginSchemes(model *v3.Document) []string {
+	var names []string
+
+	if model.Components == nil || model.Components.SecuritySchemes == nil {
+		return names
+	}
+
+	for name, scheme := range model.Components.SecuritySchemes.FromOldest() {
+		if loginFlowsOf(scheme).supported() {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Picks the scheme to log in to, the first one supporting it if unset
+func pickLoginScheme(model *v3.Document, name string) (string, loginFlows, error) {
+	schemes := loginSchemes(model)
+	if len(schemes) == 0 {
+		return "", loginFlows{}, errors.New("No security scheme supports logging in")
+	}
+
+	if name == "" {
+		name = schemes[0]
+	}
+
+	if !slices.Contains(schemes, name) {
+		return "", loginFlows{}, fmt.Errorf("Cannot log in to %s, choose one of: %s", name, strings.Join(schemes, ", "))
+	}
+
+	return name, loginFlowsOf(model.Components.SecuritySchemes.GetOrZero(name)), nil
+}
+
+// Logs in to a scheme, the one of the profile if unset, using the device grant if asked or if it's the only one supported
+func (r credentialResolver) login(ctx context.Context, w io.Writer, name string, browser, device bool) error {
+	if name == "" && slices.Contains(loginSchemes(r.model), r.authScheme) {
+		name = r.authScheme
+	}
+
+	name, flows, err := pickLoginScheme(r.model, name)
+	if err != nil {
+		return err
+	}
+
+	clientID := r.lookup(clientIDFlag)
+	if clientID == "" {
+		return fmt.Errorf("--%s is needed to log in", clientIDFlag)
+	}
+
+	var token *oauth2Token
+	switch {
+	case device || flows.authorizationCode == nil:
+		if flows.device == nil {
+			return fmt.Errorf("%s does not support the device authorization grant", name)
+		}
+
+		token, err = r.deviceGrant(ctx, w, flows, clientID)
+	default:
+		token, err = r.authorizationCodeGrant(ctx, w, flows.authorizationCode, clientID, browser)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := r.store.Set(r.profile, name, token.stored()); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Logged in to %s\n", name)
+
+	return nil
+}
+
+// Logs in via the authorization code flow with PKCE.
+// A loopback listener receives the redirect with the code, which is exchanged for tokens.
+func (r credentialResolver) authorizationCodeGrant(
+	ctx context.Context,
+	w io.Writer,
+	flow *v3.OAuthFlow,
+	clientID string,
+	browser bool,
+) (*oauth2Token, error) {
+	// 52 chars from the unreserved set, within the 43 to 128 PKCE needs
+	verifier := rand.Text() + rand.Text()
+	state := rand.Text()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	redirectURI := fmt.Sprintf("http://%s%s", listener.Addr(), loginCallback)
+	authURL, err := url.Parse(flow.AuthorizationUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", clientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if flow.Scopes != nil && flow.Scopes.Len() > 0 {
+		query.Set("scope", strings.Join(slices.Collect(flow.Scopes.KeysFromOldest()), " "))
+	}
+	authURL.RawQuery = query.Encode()
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(loginCallback, func(rw http.ResponseWriter, req *http.Request) {
+		params := req.URL.Query()
+
+		var res result
+		switch {
+		case params.Get("state") != state:
+			res.err = errors.New("Invalid state in the authorization response")
+		case params.Get("error") != "":
+			res.err = fmt.Errorf("Authorization failed: %s %s", params.Get("error"), params.Get("error_description"))
+		case params.Get("code") == "":
+			res.err = errors.New("No code in the authorization response")
+		default:
+			res.code = params.Get("code")
+		}
+
+		if res.err != nil {
+			http.Error(rw, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(rw, loginSuccessMsg)
+		}
+
+		select {
+		case results <- res:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	fmt.Fprintf(w, "Open the following URL to log in:\n\n%s\n\n", authURL)
+	if browser {
+		if err := openBrowser(authURL.String()); err != nil {
+			slog.Warn("Cannot open the browser, open the URL manually", "error", err)
+		}
+	}
+
+	var res result
+	select {
+	case res = <-results:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	return r.requestToken(ctx, flow.TokenUrl, clientID, r.lookup(clientSecretFlag), url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {res.code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+}
+
+// Removes the stored credentials of the scheme or of all the schemes if unset
+func (r credentialResolver) logout(w io.Writer, name string) error {
+	schemes := authSchemes(r.model)
+	if name != "" {
+		if !slices.Contains(schemes, name) {
+			return fmt.Errorf("Cannot log out of %s, choose one of: %s", name, strings.Join(schemes, ", "))
+		}
+
+		schemes = []string{name}
+	}
+
+	for _, scheme := range schemes {
+		stored, err := r.store.Get(r.profile, scheme)
+		if err != nil {
+			return err
+		}
+
+		if stored == nil {
+			continue
+		}
+
+		if err := r.store.Delete(r.profile, scheme); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "Logged out of %s\n", scheme)
+	}
+
+	return nil
+}
+
+// Prints where the credentials of each scheme come from and if they're still valid
+func (r credentialResolver) status(w io.Writer) error {
+	for _, name := range authSchemes(r.model) {
+		scheme := r.model.Components.SecuritySchemes.GetOrZero(name)
+		_, flags, _ := credentialTypeOf(scheme)
+
+		var set []string
+		for _, flag := range flags {
+			if r.lookup(flag) != "" {
+				set = append(set, "--"+flag)
+			}
+		}
+
+		stored, err := r.store.Get(r.profile, name)
+		if err != nil {
+			return err
+		}
+
+		var state string
+		switch {
+		case len(set) > 0 && !isOAuth2(scheme):
+			state = "set via " + strings.Join(set, ", ")
+		case stored.valid() && stored.Expiry.IsZero():
+			state = "logged in"
+		case stored.valid():
+			state = fmt.Sprintf("logged in, expires at %s", stored.Expiry.Local().Format(time.RFC3339))
+		case stored != nil && stored.RefreshToken != "":
+			state = "expired, will be refreshed on use"
+		case stored != nil:
+			state = "expired, log in again"
+		case len(set) == len(flags):
+			state = "set via " + strings.Join(set, ", ")
+		default:
+			state = "not logged in"
+		}
+
+		fmt.Fprintf(w, "%s (%s): %s\n", name, scheme.Type, state)
+	}
+
+	return nil
+}
+
+// The names of all the supported security schemes
+func authSchemes(model *v3.Document) []string {
+	var names []string
+
+	if model.Components == nil || model.Components.SecuritySchemes == nil {
+		return names
+	}
+
+	for name, scheme := range model.Components.SecuritySchemes.FromOldest() {
+		if _, _, ok := credentialTypeOf(scheme); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
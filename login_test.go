@@ -0,0 +1,216 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const loginSpec = `
+openapi: "3.0.0"
+info:
+  title: Login
+  version: "0.1.0"
+security:
+  - user:
+      - profile
+paths:
+  "/me":
+    get:
+      operationId: GetMe
+components:
+  securitySchemes:
+    user:
+      type: oauth2
+      flows:
+        authorizationCode:
+          authorizationUrl: %[1]s/authorize
+          tokenUrl: %[1]s/token
+          scopes:
+            profile: Read the profile
+            email: Read the email
+`
+
+// An authorization server redirecting back with a code right away, as if the user consented
+func authServer(t *testing.T, deny bool) *httptest.Server {
+	var challenge, redirectURI string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		assert.Equal(t, "code", params.Get("response_type"))
+		assert.Equal(t, "cli", params.Get("client_id"))
+		assert.Equal(t, "S256", params.Get("code_challenge_method"))
+		assert.Equal(t, "profile email", params.Get("scope"))
+
+		challenge = params.Get("code_challenge")
+		redirectURI = params.Get("redirect_uri")
+
+		back := url.Values{"state": {params.Get("state")}}
+		if deny {
+			back.Set("error", "access_denied")
+		} else {
+			back.Set("code", "the-code")
+		}
+
+		http.Redirect(w, r, redirectURI+"?"+back.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "cli", r.Form.Get("client_id"))
+
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			verified := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			assert.Equal(t, challenge, base64.RawURLEncoding.EncodeToString(verified[:]))
+			assert.Equal(t, "the-code", r.Form.Get("code"))
+			assert.Equal(t, redirectURI, r.Form.Get("redirect_uri"))
+
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  "user-token",
+				"expires_in":    3600,
+				"refresh_token": "user-refresh",
+			})
+		case "refresh_token":
+			assert.Equal(t, "user-refresh", r.Form.Get("refresh_token"))
+
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "refreshed-token", "expires_in": 3600})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// Plays the browser by following the authorization URL
+func fakeBrowser(t *testing.T) {
+	open := openBrowser
+	openBrowser = func(target string) error {
+		go func() {
+			resp, err := http.Get(target)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+		}()
+
+		return nil
+	}
+	t.Cleanup(func() { openBrowser = open })
+}
+
+func TestLogin(t *testing.T) {
+	fakeBrowser(t)
+
+	server := authServer(t, false)
+	model, err := LoadV3([]byte(fmt.Sprintf(loginSpec, server.URL)))
+	assert.NoError(t, err)
+
+	op := model.Model.Paths.PathItems.GetOrZero("/me").Get
+	resolver := testResolver(&model.Model, "me", map[string]string{clientIDFlag: "cli"})
+
+	creds, err := resolver.resolve(context.Background(), op)
+	assert.NoError(t, err)
+	assert.Empty(t, creds)
+
+	var out bytes.Buffer
+	assert.NoError(t, resolver.login(context.Background(), &out, "", true, false))
+	assert.Contains(t, out.String(), server.URL+"/authorize?")
+	assert.Contains(t, out.String(), "Logged in to user")
+
+	creds, err = resolver.resolve(context.Background(), op)
+	assert.NoError(t, err)
+	assert.Equal(t, []Credential{{Scheme: "user", Type: Bearer, Value: "user-token"}}, creds)
+
+	stored, err := resolver.store.Get(defaultProfile, "user")
+	assert.NoError(t, err)
+	stored.Expiry = time.Now().Add(-time.Minute)
+	assert.NoError(t, resolver.store.Set(defaultProfile, "user", *stored))
+
+	creds, err = resolver.resolve(context.Background(), op)
+	assert.NoError(t, err)
+	assert.Equal(t, "refreshed-token", creds[0].Value)
+
+	stored, err = resolver.store.Get(defaultProfile, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-refresh", stored.RefreshToken)
+
+	assert.ErrorContains(t, resolver.login(context.Background(), &out, "admin", true, false), "choose one of: user")
+}
+
+func TestLoginDenied(t *testing.T) {
+	fakeBrowser(t)
+
+	server := authServer(t, true)
+	model, err := LoadV3([]byte(fmt.Sprintf(loginSpec, server.URL)))
+	assert.NoError(t, err)
+
+	resolver := testResolver(&model.Model, "me", map[string]string{clientIDFlag: "cli"})
+
+	var out bytes.Buffer
+	assert.ErrorContains(t, resolver.login(context.Background(), &out, "user", true, false), "access_denied")
+	stored, err := resolver.store.Get(defaultProfile, "user")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestLoginCancelled(t *testing.T) {
+	model, err := LoadV3([]byte(fmt.Sprintf(loginSpec, "http://auth.invalid")))
+	assert.NoError(t, err)
+
+	resolver := testResolver(&model.Model, "me", map[string]string{clientIDFlag: "cli"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	assert.ErrorIs(t, resolver.login(ctx, &out, "", false, false), context.Canceled)
+}
+
+func TestLogoutAndStatus(t *testing.T) {
+	model, err := LoadV3([]byte(securitySpec))
+	assert.NoError(t, err)
+
+	resolver := testResolver(&model.Model, "secure", map[string]string{apiKeyFlag: "key"})
+	assert.NoError(t, resolver.store.Set(defaultProfile, "bearerAuth", StoredCredential{Value: "tok"}))
+	assert.NoError(t, resolver.store.Set(defaultProfile, "basicAuth", StoredCredential{
+		Value:  "me:secret",
+		Expiry: time.Now().Add(-time.Hour),
+	}))
+
+	var out bytes.Buffer
+	assert.NoError(t, resolver.status(&out))
+	assert.Equal(
+		t,
+		"keyAuth (apiKey): set via --api-key\n"+
+			"basicAuth (http): expired, log in again\n"+
+			"bearerAuth (http): logged in\n",
+		out.String(),
+	)
+
+	out.Reset()
+	assert.NoError(t, resolver.logout(&out, "bearerAuth"))
+	assert.Equal(t, "Logged out of bearerAuth\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, resolver.logout(&out, ""))
+	assert.Equal(t, "Logged out of basicAuth\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, resolver.status(&out))
+	assert.Contains(t, out.String(), "bearerAuth (http): not logged in")
+
+	assert.ErrorContains(t, resolver.logout(&out, "nope"), "choose one of: keyAuth, basicAuth, bearerAuth")
+}
@@ -0,0 +1,348 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pb33f/jsonpath/pkg/jsonpath"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+const (
+	noWaitFlag              = "no-wait"
+	noWaitUsage             = "Return the 202 Accepted response without polling the operation"
+	operationLocationHeader = "Operation-Location"
+)
+
+// Defaults of the long-running operations
+const (
+	lroStatus   = "$.status"
+	lroInterval = 2 * time.Second // when there's no Retry-After
+	lroTimeout  = 30 * time.Minute
+)
+
+// The terminal states of a status monitor by default, compared ignoring the case
+var (
+	lroSuccess = []string{"succeeded", "success", "completed", "done"}
+	lroFailure = []string{"failed", "canceled", "cancelled", "error"}
+)
+
+// How a 202 Accepted response is polled until the operation ends, set via x-cli-long-running or inferred from a declared 202.
+// An Operation-Location header points to a status monitor polled until a terminal state at Status,
+// a Location header to the final resource polled while it's 202 Accepted.
+type LongRunning struct {
+	Status   string   `yaml:"status"`   // a JSONPath to the state in the status monitor, $.status by default
+	Result   string   `yaml:"result"`   // a JSONPath to the URL of the final resource in the status monitor, eg $.resourceLocation
+	Success  []string `yaml:"success"`  // the states the operation succeeded in
+	Failure  []string `yaml:"failure"`  // the states the operation failed in
+	Interval string   `yaml:"interval"` // the delay before polling again unless the server sends Retry-After, 2s by default
+	Timeout  string   `yaml:"timeout"`  // how long to wait at most, 30m by default
+
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// The error of a long-running operation ending in a failure state
+type OperationError struct {
+	State   string
+	Body    any      // the decoded status monitor
+	Problem *Problem // the human readable parts of it or of its error if any
+}
+
+func (e *OperationError) Error() string {
+	msg := "The operation failed, the state is " + e.State
+	if e.Problem != nil {
+		return msg + ": " + strings.TrimPrefix(e.Problem.String(), "\n")
+	}
+
+	return msg
+}
+
+// Validates the long-running behaviour of an operation, filling in the defaults
+func makeLongRunning(op *v3.Operation, exts *extensions) (*LongRunning, error) {
+	if exts.longRunningOff {
+		return nil, nil
+	}
+
+	l := exts.longRunning
+	if l == nil {
+		if op.Responses == nil || op.Responses.Codes == nil {
+			return nil, nil
+		}
+
+		if _, ok := op.Responses.Codes.Get(strconv.Itoa(http.StatusAccepted)); !ok {
+			return nil, nil
+		}
+		l = &LongRunning{}
+	}
+
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("Invalid x-cli-long-running in %s: %s", op.OperationId, fmt.Sprintf(format, args...))
+	}
+
+	if l.Status == "" {
+		l.Status = lroStatus
+	}
+	if len(l.Success) == 0 {
+		l.Success = lroSuccess
+	}
+	if len(l.Failure) == 0 {
+		l.Failure = lroFailure
+	}
+
+	for _, path := range []string{l.Status, l.Result} {
+		if _, err := jsonpath.NewPath(path); path != "" && err != nil {
+			return nil, invalid("%s: %s", path, err)
+		}
+	}
+
+	for _, d := range []struct {
+		value    string
+		duration *time.Duration
+		fallback time.Duration
+	}{
+		{l.Interval, &l.interval, lroInterval},
+		{l.Timeout, &l.timeout, lroTimeout},
+	} {
+		*d.duration = d.fallback
+		if d.value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration <= 0 {
+			return nil, invalid("%s is not a positive duration", d.value)
+		}
+		*d.duration = duration
+	}
+
+	return l, nil
+}
+
+// The delay asked for by a Retry-After header in seconds or as a date, the fallback otherwise
+func retryAfter(header http.Header, fallback time.Duration) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return fallback
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+
+	return fallback
+}
+
+// Polls the operation accepted for req until it ends, returning the response of the final resource.
+// The accepted response is returned as is when it has nothing to poll.
+// The credentials are only sent to the status monitor and the final resource when they're on the server's origin.
+func (h HandlerData) await(req *http.Request, accepted *http.Response) (*http.Response, error) {
+	l := h.LongRunning
+
+	monitor, location, err := lroLocations(req, accepted)
+	if err != nil {
+		return nil, err
+	}
+	if monitor == "" && location == "" {
+		return accepted, nil
+	}
+	accepted.Body.Close()
+
+	ctx, cancel := context.WithTimeout(req.Context(), l.timeout)
+	defer cancel()
+
+	state := ""
+	timedOut := func() error {
+		if state == "" {
+			return fmt.Errorf("Timed out waiting for the operation after %s", l.timeout)
+		}
+
+		return fmt.Errorf("Timed out waiting for the operation after %s, the state is %s", l.timeout, state)
+	}
+
+	origin := h.origin(req.URL)
+	delay, target := retryAfter(accepted.Header, l.interval), monitor
+	if target == "" {
+		target = location
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, timedOut()
+			}
+
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		resp, err := h.get(ctx, origin, target)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, timedOut()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		delay = retryAfter(resp.Header, l.interval)
+		if resp.StatusCode >= 400 {
+			return resp, nil
+		}
+
+		// The final resource is ready once it's no longer accepted
+		if monitor == "" {
+			if resp.StatusCode != http.StatusAccepted {
+				return resp, nil
+			}
+
+			resp.Body.Close()
+			continue
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+
+		_, value, err := decodeResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+
+		if state, err = l.state(value); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case containsFold(l.Success, state):
+			result, err := l.result(req, value, location)
+			if err != nil || result == "" {
+				return resp, err
+			}
+
+			return h.get(req.Context(), origin, result)
+		case containsFold(l.Failure, state):
+			return nil, &OperationError{State: state, Body: value, Problem: monitorProblem(value)}
+		}
+	}
+}
+
+// The URLs of the status monitor and the final resource of an accepted response, resolved against the request
+func lroLocations(req *http.Request, accepted *http.Response) (monitor, location string, err error) {
+	for _, l := range []struct {
+		header string
+		url    *string
+	}{
+		{operationLocationHeader, &monitor},
+		{"Location", &location},
+	} {
+		value := accepted.Header.Get(l.header)
+		if value == "" {
+			continue
+		}
+
+		u, err := req.URL.Parse(value)
+		if err != nil {
+			return "", "", fmt.Errorf("Invalid %s %s: %w", l.header, value, err)
+		}
+		*l.url = u.String()
+	}
+
+	return monitor, location, nil
+}
+
+// The state at the status path as a string, empty if not found
+func (l *LongRunning) state(value any) (string, error) {
+	state, err := applyQuery(value, l.Status)
+	if err != nil {
+		return "", err
+	}
+
+	switch s := state.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return s, nil
+	}
+
+	return compactJSON(state), nil
+}
+
+// The URL of the final resource of a succeeded operation: the one in the status monitor, the Location
+// or the request's own one for PUT and PATCH. Empty when the status monitor is the result.
+func (l *LongRunning) result(req *http.Request, value any, location string) (string, error) {
+	if l.Result != "" {
+		result, err := applyQuery(value, l.Result)
+		if err != nil {
+			return "", err
+		}
+
+		if s, ok := result.(string); ok && s != "" {
+			u, err := req.URL.Parse(s)
+			if err != nil {
+				return "", fmt.Errorf("Invalid result URL %s: %w", s, err)
+			}
+
+			return u.String(), nil
+		}
+	}
+
+	if location != "" {
+		return location, nil
+	}
+
+	if req.Method == http.MethodPut || req.Method == http.MethodPatch {
+		return req.URL.String(), nil
+	}
+
+	return "", nil
+}
+
+// Sends a GET with the credentials of the operation when it's on its origin, without them otherwise
+func (h HandlerData) get(ctx context.Context, origin *url.URL, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.following(origin, req.URL).Do(req)
+}
+
+// The human readable parts of a failed status monitor or of its error, eg {"status": "Failed", "error": {"message": "..."}}
+func monitorProblem(value any) *Problem {
+	if obj, ok := value.(map[string]any); ok {
+		if p := problemOf(obj["error"], nil); p != nil {
+			return p
+		}
+	}
+
+	return problemOf(value, nil)
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, value) })
+}
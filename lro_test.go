@@ -0,0 +1,288 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const lroSpec = `
+openapi: "3.0.0"
+info:
+  title: Jobs
+  version: "0.1.0"
+paths:
+  "/jobs":
+    post:
+      operationId: StartJob
+      responses:
+        "202":
+          description: Accepted
+    get:
+      operationId: ListJobs
+      responses:
+        "200":
+          description: The jobs
+  "/jobs/{id}":
+    put:
+      operationId: PutJob
+      x-cli-long-running:
+        status: $.state
+        result: $.result
+        success: [ok]
+        failure: [broken]
+        interval: 1ms
+        timeout: 50ms
+      parameters:
+        - name: id
+          required: true
+          in: path
+          schema:
+            type: string
+      responses:
+        "200":
+          description: The job
+    delete:
+      operationId: DeleteJob
+      x-cli-long-running: false
+      parameters:
+        - name: id
+          required: true
+          in: path
+          schema:
+            type: string
+      responses:
+        "202":
+          description: Accepted
+  "/broken":
+    post:
+      operationId: Broken
+      x-cli-long-running:
+        timeout: soon
+      responses:
+        "202":
+          description: Accepted
+`
+
+// Accepts jobs at /{kind}, their status monitors go from Running to a state by kind
+func jobsServer(t *testing.T) *httptest.Server {
+	var (
+		mu    sync.Mutex
+		polls = map[string]int{}
+	)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "s3cret", r.Header.Get("X-API-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "0")
+		polls[r.URL.Path]++
+
+		accept := func(header, location string) {
+			w.Header().Set(header, location)
+			w.WriteHeader(http.StatusAccepted)
+		}
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /monitored":
+			w.Header().Set("Location", "/jobs/1")
+			accept(operationLocationHeader, "/operations/1")
+		case "POST /failing":
+			accept(operationLocationHeader, "/operations/2")
+		case "POST /located":
+			accept("Location", "/jobs/2")
+		case "PUT /jobs/3":
+			accept(operationLocationHeader, "/operations/3")
+		case "POST /slow":
+			accept(operationLocationHeader, "/operations/slow")
+		case "POST /queued":
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"queued":true}`)
+		case "GET /operations/1":
+			status := "Running"
+			if polls[r.URL.Path] > 1 {
+				status = "Succeeded"
+			}
+			fmt.Fprintf(w, `{"status":%q}`, status)
+		case "GET /operations/2":
+			fmt.Fprint(w, `{"status":"Failed","error":{"code":"Quota","message":"Quota exceeded"}}`)
+		case "GET /operations/3":
+			fmt.Fprint(w, `{"state":"ok","result":"/jobs/3"}`)
+		case "GET /operations/slow":
+			fmt.Fprint(w, `{"state":"pending"}`)
+		case "GET /jobs/2":
+			if polls[r.URL.Path] < 3 {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			fallthrough
+		case "GET /jobs/1", "GET /jobs/3":
+			fmt.Fprintf(w, `{"id":%q}`, r.URL.Path[len("/jobs/"):])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestMakeLongRunning(t *testing.T) {
+	model, err := LoadV3([]byte(lroSpec))
+	assert.NoError(t, err)
+
+	longRunning := func(path, method string) (*LongRunning, error) {
+		op := model.Model.Paths.PathItems.GetOrZero(path).GetOperations().GetOrZero(method)
+		exts, err := parseExtensions(op.Extensions)
+		assert.NoError(t, err)
+
+		return makeLongRunning(op, exts)
+	}
+
+	// Inferred from a declared 202
+	l, err := longRunning("/jobs", "post")
+	assert.NoError(t, err)
+	assert.Equal(t, "$.status", l.Status)
+	assert.Equal(t, lroSuccess, l.Success)
+	assert.Equal(t, lroInterval, l.interval)
+	assert.Equal(t, lroTimeout, l.timeout)
+
+	l, err = longRunning("/jobs", "get")
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+
+	l, err = longRunning("/jobs/{id}", "put")
+	assert.NoError(t, err)
+	assert.Equal(t, "$.state", l.Status)
+	assert.Equal(t, []string{"ok"}, l.Success)
+	assert.Equal(t, time.Millisecond, l.interval)
+
+	l, err = longRunning("/jobs/{id}", "delete")
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+
+	_, err = longRunning("/broken", "post")
+	assert.EqualError(t, err, "Invalid x-cli-long-running in Broken: soon is not a positive duration")
+}
+
+func TestAwait(t *testing.T) {
+	server := jobsServer(t)
+	defer server.Close()
+
+	send := func(l *LongRunning, method, path string, noWait bool) (string, error) {
+		data := HandlerData{
+			Output:      JSON,
+			LongRunning: l,
+			NoWait:      noWait,
+			Credentials: []Credential{{Type: APIKey, In: "header", Name: "X-API-Key", Value: "s3cret"}},
+		}
+		req, err := http.NewRequestWithContext(context.Background(), method, server.URL+path, nil)
+		assert.NoError(t, err)
+
+		var out bytes.Buffer
+		err = data.Send(&out, req)
+
+		return out.String(), err
+	}
+	defaults, err := makeLongRunning(nil, &extensions{longRunning: &LongRunning{}})
+	assert.NoError(t, err)
+
+	// The final resource is the Location once the status monitor succeeds
+	out, err := send(defaults, http.MethodPost, "/monitored", false)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":"1"}`+"\n", out)
+
+	// Or the Location itself once it's no longer accepted
+	out, err = send(defaults, http.MethodPost, "/located", false)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":"2"}`+"\n", out)
+
+	_, err = send(defaults, http.MethodPost, "/failing", false)
+	assert.Equal(t, "Failed", err.(*OperationError).State)
+	assert.EqualError(t, err, "The operation failed, the state is Failed: Quota exceeded")
+
+	out, err = send(defaults, http.MethodPost, "/queued", false)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"queued":true}`+"\n", out)
+
+	out, err = send(defaults, http.MethodPost, "/monitored", true)
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+
+	custom, err := makeLongRunning(nil, &extensions{
+		longRunning: &LongRunning{Status: "$.state", Result: "$.result", Success: []string{"ok"}, Timeout: "20ms"},
+	})
+	assert.NoError(t, err)
+
+	out, err = send(custom, http.MethodPut, "/jobs/3", false)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":"3"}`+"\n", out)
+
+	_, err = send(custom, http.MethodPost, "/slow", false)
+	assert.EqualError(t, err, "Timed out waiting for the operation after 20ms, the state is pending")
+}
+
+func TestAwaitOnAnotherOrigin(t *testing.T) {
+	var results []string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		results = append(results, r.URL.Path)
+		fmt.Fprint(w, `{"id":"4"}`)
+	}))
+	defer storage.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer s3cret", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "0")
+
+		switch r.URL.Path {
+		case "/jobs":
+			w.Header().Set("Location", storage.URL+"/jobs/4")
+			w.Header().Set(operationLocationHeader, "/operations/4")
+			w.WriteHeader(http.StatusAccepted)
+		case "/operations/4":
+			fmt.Fprint(w, `{"status":"Succeeded"}`)
+		}
+	}))
+	defer server.Close()
+
+	l, err := makeLongRunning(nil, &extensions{longRunning: &LongRunning{}})
+	assert.NoError(t, err)
+
+	data := HandlerData{
+		Server:      server.URL,
+		Output:      JSON,
+		LongRunning: l,
+		Credentials: []Credential{{Type: Bearer, Value: "s3cret"}},
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+"/jobs", nil)
+	assert.NoError(t, err)
+
+	// The status monitor on the server gets the credentials, the Location elsewhere doesn't
+	var out bytes.Buffer
+	assert.NoError(t, data.Send(&out, req))
+	assert.Equal(t, `{"id":"4"}`+"\n", out.String())
+	assert.Equal(t, []string{"/jobs/4"}, results)
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := http.Header{}
+	assert.Equal(t, time.Second, retryAfter(header, time.Second))
+
+	header.Set("Retry-After", "3")
+	assert.Equal(t, 3*time.Second, retryAfter(header, time.Second))
+
+	header.Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	assert.Equal(t, time.Duration(0), retryAfter(header, time.Second))
+
+	header.Set("Retry-After", "soon")
+	assert.Equal(t, time.Second, retryAfter(header, time.Second))
+}
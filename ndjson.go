@@ -0,0 +1,68 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// The media types of newline delimited JSON, a record per line
+var ndjsonMediaTypes = []string{"application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines"}
+
+// Decodes the records of a newline delimited JSON response one at a time, only the one being decoded is kept in memory.
+// The body is closed once done, stopping early included. Use any as T for plain maps, slices and scalars.
+func Records[T any](resp *http.Response) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		dec.UseNumber()
+
+		for n := 1; ; n++ {
+			var record T
+			err := dec.Decode(&record)
+			if err == io.EOF {
+				return
+			}
+
+			if err != nil {
+				yield(record, fmt.Errorf("Cannot decode record %d: %w", n, err))
+				return
+			}
+
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Renders the records of resp as they're decoded
+func (h HandlerData) streamRecords(w io.Writer, resp *http.Response) error {
+	values, err := renderer{format: h.Output, tty: isTerminal(w), columns: h.Columns, query: h.Query}.values(w)
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+
+	for record, err := range Records[any](resp) {
+		if err != nil {
+			return err
+		}
+
+		if err := values.write(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tracks whether the body was closed
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRecords(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	body := &closeTracker{Reader: strings.NewReader(`{"id":1,"name":"a"}` + "\n\n" + `{"id":2,"name":"b"}` + "\n" + `{"id":3}`)}
+
+	var items []item
+	for record, err := range Records[item](&http.Response{Body: body}) {
+		assert.NoError(t, err)
+		items = append(items, record)
+	}
+	assert.Equal(t, []item{{1, "a"}, {2, "b"}, {3, ""}}, items)
+	assert.True(t, body.closed)
+
+	// Stopping early closes the body too
+	body = &closeTracker{Reader: strings.NewReader("1\n2\n3\n")}
+	for record, err := range Records[any](&http.Response{Body: body}) {
+		assert.NoError(t, err)
+		assert.Equal(t, "1", fmt.Sprint(record))
+		break
+	}
+	assert.True(t, body.closed)
+
+	var errs []error
+	for _, err := range Records[any](&http.Response{Body: io.NopCloser(strings.NewReader("{\"id\":1}\n{oops}\n{\"id\":3}\n"))}) {
+		errs = append(errs, err)
+	}
+	assert.Len(t, errs, 2)
+	assert.NoError(t, errs[0])
+	assert.ErrorContains(t, errs[1], "Cannot decode record 2: ")
+}
+
+func TestStreamRecords(t *testing.T) {
+	proceed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"id":1,"name":"a"}`)
+		w.(http.Flusher).Flush()
+
+		// The rest is sent once the first record was rendered
+		select {
+		case <-proceed:
+		case <-time.After(5 * time.Second):
+			return
+		}
+		fmt.Fprintln(w, `{"id":2,"name":"b"}`)
+		fmt.Fprint(w, `{"id":3,"name":"c"}`)
+	}))
+	defer server.Close()
+
+	send := func(out io.Writer, output OutputFormat, query string) error {
+		data := HandlerData{Method: "get", Server: server.URL, Path: "/export", Output: output, Query: query}
+		req, err := data.NewRequest(context.Background(), nil)
+		assert.NoError(t, err)
+
+		return data.Send(out, req)
+	}
+
+	out := &firstWrite{fn: func() { close(proceed) }}
+	assert.NoError(t, send(out, JSON, ""))
+	assert.Equal(t, `{"id":1,"name":"a"}`+"\n"+`{"id":2,"name":"b"}`+"\n"+`{"id":3,"name":"c"}`+"\n", out.buf.String())
+
+	var buf bytes.Buffer
+	assert.NoError(t, send(&buf, Raw, "$.name"))
+	assert.Equal(t, "a\nb\nc\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, send(&buf, CSV, ""))
+	assert.Equal(t, "id,name\n1,a\n2,b\n3,c\n", buf.String())
+
+	model, err := LoadV3([]byte(`
+openapi: "3.0.0"
+info:
+  title: Export
+  version: "0.1.0"
+paths:
+  "/export":
+    get:
+      operationId: Export
+      responses:
+        "200":
+          description: The records
+          content:
+            application/x-ndjson:
+              schema:
+                type: object
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "application/x-ndjson", streamingMediaType(model.Model.Paths.PathItems.GetOrZero("/export").Get))
+}
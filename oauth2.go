@@ -0,0 +1,286 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+// Root flags used to fetch OAuth2 tokens
+const (
+	clientIDFlag     = "client-id"
+	clientSecretFlag = "client-secret"
+)
+
+// Tokens are considered expired a bit earlier to account for clock skew and latency
+const tokenExpiryLeeway = 30 * time.Second
+
+// A token as issued by an OAuth2 token endpoint
+type oauth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+}
+
+// An error response from an OAuth2 endpoint
+type oauth2Error struct {
+	Status      string `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauth2Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("OAuth2 endpoint returned %s", e.Status)
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("OAuth2 endpoint returned %s: %s %s", e.Status, e.Code, e.Description))
+}
+
+func (t *oauth2Token) valid() bool {
+	if t == nil {
+		return false
+	}
+
+	stored := t.stored()
+
+	return stored.valid()
+}
+
+func (t *oauth2Token) stored() StoredCredential {
+	return StoredCredential{Value: t.AccessToken, RefreshToken: t.RefreshToken, Expiry: t.Expiry}
+}
+
+func isOAuth2(scheme *v3.SecurityScheme) bool {
+	return strings.EqualFold(scheme.Type, "oauth2") && scheme.Flows != nil
+}
+
+// Gets a token for an oauth2 scheme, preferring the one stored by logging in over the client credentials flow.
+// Returns an empty token and what's needed to get one if neither is possible.
+func (r credentialResolver) oauth2Token(
+	ctx context.Context,
+	name string,
+	scheme *v3.SecurityScheme,
+	scopes []string,
+) (string, string, error) {
+	flows := scheme.Flows
+
+	login := loginFlowsOf(scheme)
+	if login.supported() {
+		if token := r.storedToken(ctx, name, login.tokenFlow()); token != "" {
+			return token, "", nil
+		}
+	}
+
+	if flow := flows.ClientCredentials; flow != nil {
+		clientID, clientSecret := r.lookup(clientIDFlag), r.lookup(clientSecretFlag)
+		if clientID != "" && clientSecret != "" {
+			token, err := r.clientCredentialsToken(ctx, flow, clientID, clientSecret, scopes)
+
+			return token, "", err
+		}
+	}
+
+	var needs []string
+	if flows.ClientCredentials != nil {
+		needs = append(needs, fmt.Sprintf("--%s and --%s", clientIDFlag, clientSecretFlag))
+	}
+	if login.supported() {
+		needs = append(needs, fmt.Sprintf("%s %s", r.rootName, loginCmd))
+	}
+
+	return "", strings.Join(needs, " or "), nil
+}
+
+// Returns the token stored from logging in if still valid, refreshing it if possible otherwise
+func (r credentialResolver) storedToken(ctx context.Context, scheme string, flow *v3.OAuthFlow) string {
+	stored, err := r.store.Get(r.profile, scheme)
+	if err != nil {
+		slog.Warn("Cannot read the stored credentials", "error", err)
+
+		return ""
+	}
+
+	if stored.valid() {
+		return stored.Value
+	}
+
+	if stored == nil || stored.RefreshToken == "" {
+		return ""
+	}
+
+	token, err := r.refreshToken(ctx, flow, r.lookup(clientIDFlag), r.lookup(clientSecretFlag), stored.RefreshToken)
+	if err != nil {
+		slog.Warn("Cannot refresh token, log in again", "error", err)
+
+		return ""
+	}
+
+	if err := r.store.Set(r.profile, scheme, token.stored()); err != nil {
+		slog.Warn("Cannot store token", "scheme", scheme, "error", err)
+	}
+
+	return token.AccessToken
+}
+
+func (r credentialResolver) refreshToken(
+	ctx context.Context,
+	flow *v3.OAuthFlow,
+	clientID, clientSecret, refreshToken string,
+) (*oauth2Token, error) {
+	refreshURL := flow.RefreshUrl
+	if refreshURL == "" {
+		refreshURL = flow.TokenUrl
+	}
+
+	token, err := r.requestToken(ctx, refreshURL, clientID, clientSecret, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// servers may not rotate refresh tokens
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+
+	return token, nil
+}
+
+// Returns the stored token for the client and scopes if still valid, refreshing or fetching a new one otherwise
+func (r credentialResolver) clientCredentialsToken(
+	ctx context.Context,
+	flow *v3.OAuthFlow,
+	clientID, clientSecret string,
+	scopes []string,
+) (string, error) {
+	key := clientCredentialsKey(flow.TokenUrl, clientID, scopes)
+	stored, err := r.store.Get(r.profile, key)
+	if err != nil {
+		slog.Warn("Cannot read the stored credentials", "error", err)
+	}
+
+	if stored.valid() {
+		return stored.Value, nil
+	}
+
+	var token *oauth2Token
+	if stored != nil && stored.RefreshToken != "" {
+		refreshed, err := r.refreshToken(ctx, flow, clientID, clientSecret, stored.RefreshToken)
+		if err != nil {
+			slog.Warn("Cannot refresh token, fetching a new one", "error", err)
+		} else {
+			token = refreshed
+		}
+	}
+
+	if token == nil {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if len(scopes) > 0 {
+			form.Set("scope", strings.Join(scopes, " "))
+		}
+
+		fetched, err := r.requestToken(ctx, flow.TokenUrl, clientID, clientSecret, form)
+		if err != nil {
+			return "", err
+		}
+
+		token = fetched
+	}
+
+	if err := r.store.Set(r.profile, key, token.stored()); err != nil {
+		slog.Warn("Cannot store token", "error", err)
+	}
+
+	return token.AccessToken, nil
+}
+
+// Client credentials tokens are stored per token endpoint, client and scopes, apart from the schemes logged in to
+func clientCredentialsKey(tokenURL, clientID string, scopes []string) string {
+	key := sha256.Sum256([]byte(strings.Join([]string{tokenURL, clientID, strings.Join(scopes, " ")}, "\n")))
+
+	return "client-credentials:" + hex.EncodeToString(key[:])
+}
+
+// Posts the form to the token endpoint authenticating the client with basic auth.
+// Public clients without a secret only identify themselves.
+func (r credentialResolver) requestToken(
+	ctx context.Context,
+	tokenURL, clientID, clientSecret string,
+	form url.Values,
+) (*oauth2Token, error) {
+	if clientSecret == "" {
+		form.Set("client_id", clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	client := r.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &oauth2Error{Status: resp.Status}
+		json.Unmarshal(body, tokenErr)
+
+		return nil, tokenErr
+	}
+
+	var token oauth2Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("Invalid token response: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return nil, errors.New("Token endpoint returned no access_token")
+	}
+
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return &token, nil
+}
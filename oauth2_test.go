@@ -0,0 +1,146 @@
+package climate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const oauth2Spec = `
+openapi: "3.0.0"
+info:
+  title: OAuth2
+  version: "0.1.0"
+paths:
+  "/reports":
+    get:
+      operationId: GetReports
+      security:
+        - machine:
+            - reports:read
+            - reports:list
+components:
+  securitySchemes:
+    machine:
+      type: oauth2
+      flows:
+        clientCredentials:
+          tokenUrl: %s/token
+          scopes:
+            reports:read: Read reports
+            reports:list: List reports
+`
+
+// A token endpoint issuing numbered tokens, failing for unknown clients
+func tokenServer(t *testing.T, grants *[]string) (*httptest.Server, *atomic.Int32) {
+	var issued atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "reporter" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_client", "error_description": "Unknown client"}`)
+
+			return
+		}
+
+		*grants = append(*grants, r.Form.Get("grant_type")+" "+r.Form.Get("scope"))
+		n := issued.Add(1)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("token-%d", n),
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &issued
+}
+
+func TestClientCredentials(t *testing.T) {
+	var grants []string
+	server, issued := tokenServer(t, &grants)
+	model, err := LoadV3([]byte(fmt.Sprintf(oauth2Spec, server.URL)))
+	assert.NoError(t, err)
+
+	op := model.Model.Paths.PathItems.GetOrZero("/reports").Get
+	flags := map[string]string{clientIDFlag: "reporter", clientSecretFlag: "s3cret"}
+	resolver := testResolver(&model.Model, "reports", flags)
+
+	creds, err := resolver.resolve(context.Background(), op)
+	assert.NoError(t, err)
+	assert.Equal(t, []Credential{{Scheme: "machine", Type: Bearer, Value: "token-1"}}, creds)
+
+	// served from the store
+	creds, err = resolver.resolve(context.Background(), op)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", creds[0].Value)
+	assert.Equal(t, int32(1), issued.Load())
+
+	// expired tokens are refreshed
+	key := clientCredentialsKey(server.URL+"/token", "reporter", []string{"reports:read", "reports:list"})
+	stored, err := resolver.store.Get(defaultProfile, key)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", stored.Value)
+	assert.NoError(t, resolver.store.Set(defaultProfile, key, StoredCredential{
+		Value:        "token-1",
+		RefreshToken: "refresh-1",
+		Expiry:       time.Now().Add(-time.Minute),
+	}))
+
+	creds, err = resolver.resolve(context.Background(), op)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", creds[0].Value)
+	assert.Equal(t, []string{"client_credentials reports:read reports:list", "refresh_token "}, grants)
+
+	flags[clientSecretFlag] = "wrong"
+	assert.NoError(t, resolver.store.Set(defaultProfile, key, StoredCredential{Value: "stale", Expiry: time.Now()}))
+	_, err = resolver.resolve(context.Background(), op)
+	assert.ErrorContains(t, err, "invalid_client Unknown client")
+}
+
+func TestClientCredentialsReplayed(t *testing.T) {
+	var grants []string
+	server, issued := tokenServer(t, &grants)
+	model, err := LoadV3([]byte(fmt.Sprintf(oauth2Spec, server.URL)))
+	assert.NoError(t, err)
+
+	// No token is fetched for the requests answered from an archive, with or without the client flags
+	for _, flags := range []map[string]string{{}, {clientIDFlag: "reporter", clientSecretFlag: "s3cret"}} {
+		resolver := testResolver(&model.Model, "reports", flags)
+		resolver.replay = true
+
+		creds, err := resolver.resolve(context.Background(), model.Model.Paths.PathItems.GetOrZero("/reports").Get)
+		assert.NoError(t, err)
+		assert.Equal(t, []Credential{{Scheme: "machine", Type: Bearer, Value: masked}}, creds)
+	}
+	assert.Zero(t, issued.Load())
+}
+
+func TestTokenValid(t *testing.T) {
+	var missing *oauth2Token
+	assert.False(t, missing.valid())
+	assert.False(t, (&oauth2Token{}).valid())
+	assert.True(t, (&oauth2Token{AccessToken: "t"}).valid())
+	assert.True(t, (&oauth2Token{AccessToken: "t", Expiry: time.Now().Add(time.Hour)}).valid())
+	assert.False(t, (&oauth2Token{AccessToken: "t", Expiry: time.Now().Add(time.Second)}).valid())
+}
+
+func TestClientCredentialsKey(t *testing.T) {
+	key := clientCredentialsKey("https://auth/token", "id", []string{"a", "b"})
+	assert.True(t, strings.HasPrefix(key, "client-credentials:"))
+	assert.NotEqual(t, key, clientCredentialsKey("https://auth/token", "id", []string{"a"}))
+}
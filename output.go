@@ -0,0 +1,605 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"go.yaml.in/yaml/v4"
+)
+
+// Currently supported output formats
+type OutputFormat string
+
+const (
+	JSON  OutputFormat = "json"
+	YAML  OutputFormat = "yaml"
+	Raw   OutputFormat = "raw"
+	Table OutputFormat = "table"
+	Wide  OutputFormat = "wide" // a table with all the fields
+	CSV   OutputFormat = "csv"
+)
+
+var outputFormats = []OutputFormat{JSON, YAML, Raw, Table, Wide, CSV}
+
+const (
+	outputFlag  = "output"
+	outputUsage = "The output format: json, yaml, raw, table, wide or csv"
+)
+
+// ANSI colors of the JSON tokens
+const (
+	colorReset  = "\x1b[0m"
+	colorKey    = "\x1b[34;1m"
+	colorString = "\x1b[32m"
+	colorNumber = "\x1b[36m"
+	colorBool   = "\x1b[33m"
+	colorNull   = "\x1b[90m"
+)
+
+func parseOutputFormat(format string) (OutputFormat, error) {
+	if f := OutputFormat(strings.ToLower(format)); slices.Contains(outputFormats, f) {
+		return f, nil
+	}
+
+	var names []string
+	for _, f := range outputFormats {
+		names = append(names, string(f))
+	}
+
+	return "", fmt.Errorf("Unknown output format %s, choose one of: %s", format, strings.Join(names, ", "))
+}
+
+// Renders a response in the format, eg the one in HandlerData.Output.
+// The response can be an *http.Response or a []byte, decoded by the content type, or any value encodable as JSON.
+// When w is a terminal, JSON is pretty-printed and colored unless NO_COLOR is set.
+func Render(w io.Writer, resp any, format OutputFormat) error {
+	return renderer{format: format, tty: isTerminal(w)}.render(w, resp)
+}
+
+// Renders a response in the selected output format, filtered by the query if any.
+// Tables have the columns of the operation unless filtered.
+// An *http.Response is validated against the spec first, see ValidateResponse.
+// An error response is returned as an *HTTPError, its problem parsed with the error schemas of the operation.
+func (h HandlerData) Render(w io.Writer, resp any) error {
+	if r, ok := resp.(*http.Response); ok {
+		if err := h.ValidateResponse(r); err != nil {
+			return err
+		}
+
+		if r.StatusCode >= 400 {
+			httpErr := newHTTPError(r, h.responses)
+
+			// The message of the error is concise, the full payload is shown when asked for JSON
+			if h.Output == JSON && h.outputSet && httpErr.Body != nil {
+				if err := (renderer{format: JSON, tty: isTerminal(w)}).render(w, httpErr.Body); err != nil {
+					return err
+				}
+			}
+
+			return httpErr
+		}
+	}
+
+	return renderer{format: h.Output, tty: isTerminal(w), columns: h.Columns, query: h.Query}.render(w, resp)
+}
+
+type renderer struct {
+	format  OutputFormat
+	tty     bool     // pretty-prints and colors JSON
+	columns []Column // of tables, all the fields if unset
+	query   string   // a JSONPath selecting what to render
+}
+
+func (r renderer) render(w io.Writer, resp any) error {
+	format, err := parseOutputFormat(string(r.format))
+	if err != nil {
+		return err
+	}
+
+	raw, value, err := decodeResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	// Nothing to render for empty bodies
+	if raw != nil && len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+
+	columns := r.columns
+	if r.query != "" {
+		if value, err = applyQuery(value, r.query); err != nil {
+			return err
+		}
+
+		// The columns of the operation are for the whole response
+		raw, columns = nil, nil
+	}
+
+	switch format {
+	case Raw:
+		if raw == nil {
+			if raw, err = json.Marshal(value); err != nil {
+				return err
+			}
+		}
+
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+
+		if !bytes.HasSuffix(raw, []byte("\n")) {
+			_, err = io.WriteString(w, "\n")
+		}
+
+		return err
+	case YAML:
+		return yaml.NewEncoder(w).Encode(yamlNumbers(value))
+	case Table, Wide:
+		return writeTable(w, value, columns, format == Wide)
+	case CSV:
+		return writeCSV(w, value, columns)
+	}
+
+	if r.tty && os.Getenv("NO_COLOR") == "" {
+		var b strings.Builder
+		writeColorJSON(&b, value, 0)
+		b.WriteString("\n")
+		_, err := io.WriteString(w, b.String())
+
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if r.tty {
+		enc.SetIndent("", "  ")
+	}
+
+	return enc.Encode(value)
+}
+
+// Renders the items of a list as they arrive, eg from the pages of a response.
+// Tables have the columns fixed by the first items. With a query, the items are rendered at once on closing.
+type stream struct {
+	renderer
+	w        io.Writer
+	count    int
+	columns  []Column
+	buffered []any
+	widths   []int
+	cw       *csv.Writer
+}
+
+func (r renderer) stream(w io.Writer) (*stream, error) {
+	format, err := parseOutputFormat(string(r.format))
+	if err != nil {
+		return nil, err
+	}
+	r.format = format
+
+	return &stream{renderer: r, w: w, buffered: []any{}}, nil
+}
+
+func (s *stream) write(items []any) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	normalized, err := normalizeJSON(items)
+	if err != nil {
+		return err
+	}
+	items = normalized.([]any)
+
+	if s.query != "" {
+		s.buffered = append(s.buffered, items...)
+		return nil
+	}
+
+	switch s.format {
+	case Raw:
+		for _, item := range items {
+			if _, err := io.WriteString(s.w, compactJSON(item)+"\n"); err != nil {
+				return err
+			}
+		}
+	case YAML:
+		if err := yaml.NewEncoder(s.w).Encode(yamlNumbers(items)); err != nil {
+			return err
+		}
+	case Table, Wide, CSV:
+		if err := s.writeRows(items); err != nil {
+			return err
+		}
+	default:
+		var b strings.Builder
+		for _, item := range items {
+			if s.count == 0 {
+				b.WriteString("[")
+			} else {
+				b.WriteString(",")
+			}
+			s.count++
+
+			switch {
+			case s.tty && os.Getenv("NO_COLOR") == "":
+				b.WriteString("\n  ")
+				writeColorJSON(&b, item, 1)
+			case s.tty:
+				b.WriteString("\n  " + indentJSON(item, "  "))
+			default:
+				b.WriteString(compactJSON(item))
+			}
+		}
+
+		_, err := io.WriteString(s.w, b.String())
+
+		return err
+	}
+
+	s.count += len(items)
+
+	return nil
+}
+
+// Writes the rows of a batch of items as they arrive.
+// The columns of a table get their widths from the first batch, the later cells are padded or truncated to them.
+func (s *stream) writeRows(items []any) error {
+	first := s.columns == nil
+	if first {
+		s.columns = tableColumns(items, s.renderer.columns, s.format == Wide)
+	}
+
+	rows, err := tableRows(items, s.columns)
+	if err != nil {
+		return err
+	}
+
+	if first {
+		var headers []string
+		for _, column := range s.columns {
+			headers = append(headers, column.Header)
+		}
+
+		if s.format == CSV {
+			s.cw = csv.NewWriter(s.w)
+			if err := s.cw.Write(headers); err != nil {
+				return err
+			}
+		} else {
+			for i, header := range headers {
+				headers[i] = strings.ToUpper(header)
+			}
+
+			s.widths = make([]int, len(headers))
+			for _, row := range append([][]string{headers}, rows...) {
+				for i, cell := range row {
+					s.widths[i] = max(s.widths[i], utf8.RuneCountInString(cell))
+				}
+			}
+
+			rows = append([][]string{headers}, rows...)
+		}
+	}
+
+	if s.cw != nil {
+		s.cw.WriteAll(rows)
+		return s.cw.Error()
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(row)-1 {
+				b.WriteString(cell)
+				break
+			}
+
+			b.WriteString(fitCell(cell, s.widths[i]) + "   ")
+		}
+		b.WriteString("\n")
+	}
+
+	_, err = io.WriteString(s.w, b.String())
+
+	return err
+}
+
+// The cell padded to width, or truncated with an ellipsis when it's longer
+func fitCell(cell string, width int) string {
+	runes := []rune(cell)
+	if len(runes) > width {
+		return string(runes[:max(width-1, 0)]) + "…"
+	}
+
+	return cell + strings.Repeat(" ", width-len(runes))
+}
+
+func (s *stream) close() error {
+	if s.query != "" {
+		return s.render(s.w, s.buffered)
+	}
+
+	var err error
+	switch s.format {
+	case JSON:
+		switch {
+		case s.count == 0:
+			_, err = io.WriteString(s.w, "[]\n")
+		case s.tty:
+			_, err = io.WriteString(s.w, "\n]\n")
+		default:
+			_, err = io.WriteString(s.w, "]\n")
+		}
+	case YAML:
+		if s.count == 0 {
+			_, err = io.WriteString(s.w, "[]\n")
+		}
+	}
+
+	return err
+}
+
+// Renders the values of a streamed response as they arrive, the query is applied to each of them.
+// JSON is written a value per line, as is raw output except for strings written without quotes.
+type valueStream struct {
+	rows  *stream // for the other formats
+	query string
+}
+
+func (r renderer) values(w io.Writer) (*valueStream, error) {
+	query := r.query
+	r.query = ""
+
+	rows, err := r.stream(w)
+	if err != nil {
+		return nil, err
+	}
+
+	return &valueStream{rows: rows, query: query}, nil
+}
+
+func (v *valueStream) write(value any) error {
+	value, err := normalizeJSON(value)
+	if err != nil {
+		return err
+	}
+
+	if v.query != "" {
+		if value, err = applyQuery(value, v.query); err != nil {
+			return err
+		}
+
+		// Values the query selects nothing from are skipped
+		if selected, ok := value.([]any); value == nil || ok && len(selected) == 0 {
+			return nil
+		}
+	}
+
+	switch v.rows.format {
+	case JSON:
+		_, err = io.WriteString(v.rows.w, compactJSON(value)+"\n")
+	case Raw:
+		s, ok := value.(string)
+		if !ok {
+			s = compactJSON(value)
+		}
+		_, err = io.WriteString(v.rows.w, s+"\n")
+	default:
+		err = v.rows.write([]any{value})
+	}
+
+	return err
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Decodes a response into plain maps, slices and scalars, keeping the undecoded bytes if any
+func decodeResponse(resp any) ([]byte, any, error) {
+	var (
+		data        []byte
+		contentType string
+	)
+
+	switch r := resp.(type) {
+	case *http.Response:
+		defer r.Body.Close()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, contentType = body, r.Header.Get("Content-Type")
+	case []byte:
+		data = r
+	case json.RawMessage:
+		data = r
+	default:
+		// Round trip to honour the json tags of structs
+		value, err := normalizeJSON(resp)
+
+		return nil, value, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	var value any
+	switch {
+	case len(bytes.TrimSpace(data)) == 0:
+		return data, nil, nil
+	case strings.Contains(mediaType, "yaml"):
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, nil, err
+		}
+
+		var err error
+		if value, err = normalizeJSON(value); err != nil {
+			return nil, nil, err
+		}
+	case mediaType == "" || strings.Contains(mediaType, "json"):
+		if err := decodeJSON(data, &value); err != nil {
+			if mediaType != "" {
+				return nil, nil, fmt.Errorf("Cannot decode response: %w", err)
+			}
+
+			value = string(data)
+		}
+	default:
+		value = string(data)
+	}
+
+	return data, value, nil
+}
+
+// Converts a value to the types decoded from JSON
+func normalizeJSON(value any) (any, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized any
+	if err := decodeJSON(encoded, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// Decodes JSON keeping the numbers as they are
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return dec.Decode(v)
+}
+
+// Encodes the numbers as they are in YAML, they'd be quoted strings otherwise
+func yamlNumbers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		converted := make(map[string]any, len(v))
+		for key, val := range v {
+			converted[key] = yamlNumbers(val)
+		}
+
+		return converted
+	case []any:
+		converted := make([]any, len(v))
+		for i, val := range v {
+			converted[i] = yamlNumbers(val)
+		}
+
+		return converted
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	}
+
+	return value
+}
+
+func writeColorJSON(b *strings.Builder, value any, depth int) {
+	indent := func(depth int) {
+		b.WriteString(strings.Repeat("  ", depth))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			b.WriteString("{}")
+			return
+		}
+
+		b.WriteString("{\n")
+		keys := slices.Sorted(maps.Keys(v))
+		for i, key := range keys {
+			indent(depth + 1)
+			b.WriteString(colorKey + compactJSON(key) + colorReset + ": ")
+			writeColorJSON(b, v[key], depth+1)
+
+			if i < len(keys)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		indent(depth)
+		b.WriteString("}")
+	case []any:
+		if len(v) == 0 {
+			b.WriteString("[]")
+			return
+		}
+
+		b.WriteString("[\n")
+		for i, item := range v {
+			indent(depth + 1)
+			writeColorJSON(b, item, depth+1)
+
+			if i < len(v)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		indent(depth)
+		b.WriteString("]")
+	case string:
+		b.WriteString(colorString + compactJSON(v) + colorReset)
+	case json.Number:
+		b.WriteString(colorNumber + v.String() + colorReset)
+	case bool:
+		b.WriteString(fmt.Sprintf("%s%t%s", colorBool, v, colorReset))
+	case nil:
+		b.WriteString(colorNull + "null" + colorReset)
+	}
+}
+
+func indentJSON(value any, prefix string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(prefix, "  ")
+	enc.Encode(value)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func compactJSON(value any) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
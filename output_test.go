@@ -0,0 +1,95 @@
+package climate
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	format, err := parseOutputFormat("YAML")
+	assert.NoError(t, err)
+	assert.Equal(t, YAML, format)
+
+	_, err = parseOutputFormat("xml")
+	assert.ErrorContains(t, err, "Unknown output format xml, choose one of: json, yaml, raw, table, wide, csv")
+}
+
+func TestRender(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Size  int    `json:"size"`
+		Owner *struct {
+			ID string `json:"id"`
+		} `json:"owner,omitempty"`
+	}
+	items := []item{{Name: "a", Size: 1}, {Name: "b<c>", Size: 22}}
+	response := func(contentType, body string) *http.Response {
+		return &http.Response{
+			Header: http.Header{"Content-Type": {contentType}},
+			Body:   io.NopCloser(strings.NewReader(body)),
+		}
+	}
+	rendered := func(resp any, format OutputFormat, tty bool) string {
+		var out bytes.Buffer
+		assert.NoError(t, renderer{format: format, tty: tty}.render(&out, resp))
+
+		return out.String()
+	}
+
+	assert.Equal(t, `[{"name":"a","size":1},{"name":"b<c>","size":22}]`+"\n", rendered(items, JSON, false))
+	assert.Equal(t, "- name: a\n  size: 1\n- name: b<c>\n  size: 22\n", rendered(items, YAML, false))
+	assert.Equal(t, "NAME   SIZE\na      1\nb<c>   22\n", rendered(items, Table, false))
+	assert.Equal(t, "name,size\na,1\nb<c>,22\n", rendered(items, CSV, false))
+
+	resp := response("application/json; charset=utf-8", `{"ok": true, "tags": ["x"], "none": null}`)
+	assert.Equal(t, "NONE   OK     TAGS\n       true   [\"x\"]\n", rendered(resp, Table, false))
+
+	resp = response("application/json", `{"ok": true}`)
+	assert.Equal(t, `{"ok": true}`+"\n", rendered(resp, Raw, false))
+
+	resp = response("application/yaml", "ok: true\ncount: 2\n")
+	assert.Equal(t, `{"count":2,"ok":true}`+"\n", rendered(resp, JSON, false))
+	assert.Equal(t, "big: 12345678901234567890\nratio: 0.5\n", rendered([]byte(`{"big":12345678901234567890,"ratio":0.5}`), YAML, false))
+
+	resp = response("text/plain", "pong")
+	assert.Equal(t, "pong\n", rendered(resp, Raw, false))
+	assert.Equal(t, `"pong"`+"\n", rendered(response("text/plain", "pong"), JSON, false))
+	assert.Equal(t, "VALUE\npong\n", rendered(response("text/plain", "pong"), Table, false))
+
+	assert.Empty(t, rendered(response("application/json", ""), JSON, false))
+	assert.Error(t, renderer{format: JSON}.render(io.Discard, response("application/json", "{")))
+	assert.Error(t, renderer{format: "xml"}.render(io.Discard, items))
+}
+
+func TestRenderColors(t *testing.T) {
+	var out bytes.Buffer
+
+	assert.NoError(t, renderer{format: JSON, tty: true}.render(&out, map[string]any{"a": []any{"x", 1, true, nil}, "b": map[string]any{}}))
+	assert.Equal(
+		t,
+		"{\n"+
+			"  "+colorKey+`"a"`+colorReset+": [\n"+
+			"    "+colorString+`"x"`+colorReset+",\n"+
+			"    "+colorNumber+"1"+colorReset+",\n"+
+			"    "+colorBool+"true"+colorReset+",\n"+
+			"    "+colorNull+"null"+colorReset+"\n"+
+			"  ],\n"+
+			"  "+colorKey+`"b"`+colorReset+": {}\n"+
+			"}\n",
+		out.String(),
+	)
+
+	t.Setenv("NO_COLOR", "1")
+	out.Reset()
+	assert.NoError(t, renderer{format: JSON, tty: true}.render(&out, map[string]any{"a": 1}))
+	assert.Equal(t, "{\n  \"a\": 1\n}\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, renderer{format: JSON, tty: true}.render(&out, []byte(`{"id":12345678901234567890}`)))
+	assert.Equal(t, "{\n  \"id\": 12345678901234567890\n}\n", out.String())
+}
@@ -0,0 +1,269 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pb33f/jsonpath/pkg/jsonpath"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+// Currently supported pagination strategies
+const (
+	CursorPagination = "cursor" // the next cursor from the response is passed as a query param
+	OffsetPagination = "offset" // the offset query param is advanced by the number of items
+	LinkPagination   = "link"   // the next page is the rel="next" URL of the Link header
+)
+
+var paginationStrategies = []string{CursorPagination, OffsetPagination, LinkPagination}
+
+const (
+	allFlag       = "all"
+	allUsage      = "Fetch all the pages"
+	maxItemsFlag  = "max-items"
+	maxItemsUsage = "Fetch pages until these many items, all of them if 0"
+)
+
+// How the pages of a list operation are followed, set via x-cli-pagination
+type Pagination struct {
+	Strategy    string `yaml:"strategy"`     // one of cursor, offset or link
+	Items       string `yaml:"items"`        // a JSONPath to the items of a page, eg $.data, the whole page if unset
+	CursorParam string `yaml:"cursor-param"` // the query param of the cursor
+	NextCursor  string `yaml:"next-cursor"`  // a JSONPath to the cursor of the next page, eg $.meta.next
+	OffsetParam string `yaml:"offset-param"` // the query param of the offset
+	LimitParam  string `yaml:"limit-param"`  // the query param of the page size, a shorter page is the last one
+
+	columns []Column // of the items
+}
+
+// Validates the pagination of an operation, finding the columns of its items
+func makePagination(op *v3.Operation, exts *extensions) (*Pagination, error) {
+	p := exts.pagination
+	if p == nil {
+		return nil, nil
+	}
+
+	if err := p.validate(op.OperationId); err != nil {
+		return nil, err
+	}
+
+	columns, err := makeItemColumns(op, exts)
+	if err != nil {
+		return nil, err
+	}
+	p.columns = columns
+
+	return p, nil
+}
+
+func (p *Pagination) validate(id string) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("Invalid x-cli-pagination in %s: %s", id, fmt.Sprintf(format, args...))
+	}
+
+	switch p.Strategy {
+	case CursorPagination:
+		if p.CursorParam == "" || p.NextCursor == "" {
+			return invalid("cursor-param and next-cursor are needed for the cursor strategy")
+		}
+	case OffsetPagination:
+		if p.OffsetParam == "" {
+			return invalid("offset-param is needed for the offset strategy")
+		}
+	case LinkPagination:
+	default:
+		return invalid("unknown strategy %s, choose one of: %s", p.Strategy, strings.Join(paginationStrategies, ", "))
+	}
+
+	for _, path := range []string{p.Items, p.NextCursor} {
+		if _, err := jsonpath.NewPath(path); path != "" && err != nil {
+			return invalid("%s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// The items of a page, a list at the path or the matches of the path
+func (p *Pagination) items(page any) ([]any, error) {
+	if p.Items != "" {
+		var err error
+		if page, err = applyQuery(page, p.Items); err != nil {
+			return nil, err
+		}
+	}
+
+	switch items := page.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		return items, nil
+	default:
+		return []any{items}, nil
+	}
+}
+
+// The request of the page after the one of req, nil if it was the last one
+func (p *Pagination) next(req *http.Request, resp *http.Response, page any, count int) (*http.Request, error) {
+	u := *req.URL
+	query := u.Query()
+
+	switch p.Strategy {
+	case CursorPagination:
+		cursor, err := applyQuery(page, p.NextCursor)
+		if err != nil {
+			return nil, err
+		}
+
+		var value string
+		switch c := cursor.(type) {
+		case nil:
+		case string:
+			value = c
+		case json.Number:
+			value = c.String()
+		default:
+			value = compactJSON(c)
+		}
+
+		if value == "" || value == query.Get(p.CursorParam) {
+			return nil, nil
+		}
+		query.Set(p.CursorParam, value)
+	case OffsetPagination:
+		if count == 0 {
+			return nil, nil
+		}
+
+		if limit, err := strconv.Atoi(query.Get(p.LimitParam)); err == nil && count < limit {
+			return nil, nil
+		}
+
+		offset, _ := strconv.Atoi(query.Get(p.OffsetParam))
+		query.Set(p.OffsetParam, strconv.Itoa(offset+count))
+	case LinkPagination:
+		link := nextLink(resp.Header.Values("Link"))
+		if link == "" {
+			return nil, nil
+		}
+
+		next, err := req.URL.Parse(link)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid next link %s: %w", link, err)
+		}
+
+		if next.String() == req.URL.String() {
+			return nil, nil
+		}
+		u, query = *next, next.Query()
+	}
+
+	u.RawQuery = query.Encode()
+
+	next := req.Clone(req.Context())
+	next.URL = &u
+	next.Host = u.Host
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+
+	return next, nil
+}
+
+// The URL of rel="next" in Link headers, eg <https://api.example.com/items?page=2>; rel="next"
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for link := range strings.SplitSeq(header, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if !ok {
+				continue
+			}
+
+			for param := range strings.SplitSeq(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "rel") && slices.Contains(strings.Fields(strings.Trim(value, `"`)), "next") {
+					return strings.Trim(strings.TrimSpace(target), "<>")
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// Requests the pages from req, rendering their items as they arrive until the last page or MaxItems.
+// The credentials are only sent to the pages on the same origin as the server.
+func (h HandlerData) paginate(w io.Writer, req *http.Request) (err error) {
+	p := h.Pagination
+
+	s, err := renderer{format: h.Output, tty: isTerminal(w), columns: p.columns, query: h.Query}.stream(w)
+	if err != nil {
+		return err
+	}
+	// The items written so far stay valid JSON when a page fails
+	defer func() {
+		if closeErr := s.close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	origin, count := h.origin(req.URL), 0
+	for req != nil {
+		resp, err := h.following(origin, req.URL).Do(req)
+		if err != nil {
+			return err
+		}
+
+		if err := h.ValidateResponse(resp); err != nil {
+			resp.Body.Close()
+			return err
+		}
+
+		if resp.StatusCode >= 400 {
+			return newHTTPError(resp, h.responses)
+		}
+
+		_, page, err := decodeResponse(resp)
+		if err != nil {
+			return err
+		}
+
+		items, err := p.items(page)
+		if err != nil {
+			return err
+		}
+
+		next, err := p.next(req, resp, page, len(items))
+		if err != nil {
+			return err
+		}
+		req = next
+
+		if h.MaxItems > 0 && count+len(items) >= h.MaxItems {
+			items, req = items[:h.MaxItems-count], nil
+		}
+		count += len(items)
+
+		if err := s.write(items); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
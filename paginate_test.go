@@ -0,0 +1,323 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const paginateSpec = `
+openapi: "3.0.0"
+info:
+  title: Pages
+  version: "0.1.0"
+paths:
+  "/cursor":
+    get:
+      operationId: ListByCursor
+      x-cli-pagination:
+        strategy: cursor
+        items: $.data.items
+        cursor-param: after
+        next-cursor: $.meta.next
+      responses:
+        "200":
+          description: A page
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  data:
+                    type: object
+                    properties:
+                      items:
+                        type: array
+                        items:
+                          type: object
+                          properties:
+                            id:
+                              type: integer
+                            tags:
+                              type: array
+                  meta:
+                    type: object
+                    properties:
+                      next:
+                        type: string
+  "/offset":
+    get:
+      operationId: ListByOffset
+      x-cli-pagination:
+        strategy: offset
+        offset-param: offset
+        limit-param: limit
+      responses:
+        "200":
+          description: A page
+  "/link":
+    get:
+      operationId: ListByLink
+      x-cli-pagination:
+        strategy: link
+      responses:
+        "200":
+          description: A page
+  "/broken":
+    get:
+      operationId: Broken
+      x-cli-pagination:
+        strategy: cursor
+      responses:
+        "200":
+          description: A page
+`
+
+// Serves 7 items in pages of 3 by cursor, offset or link
+func pagesServer(t *testing.T, requests *[]string) *httptest.Server {
+	const total, size = 7, 3
+
+	page := func(from int) []map[string]any {
+		var items []map[string]any
+		for i := from; i < min(from+size, total); i++ {
+			items = append(items, map[string]any{"id": i})
+		}
+
+		return items
+	}
+	from := func(r *http.Request, param string) int {
+		n, _ := strconv.Atoi(r.URL.Query().Get(param))
+		return n
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RequestURI())
+		assert.Equal(t, "s3cret", r.Header.Get("X-API-Key"))
+		w.Header().Set("Content-Type", "application/json")
+
+		var body any
+		switch r.URL.Path {
+		case "/cursor":
+			start, next := from(r, "after"), ""
+			if start+size < total {
+				next = strconv.Itoa(start + size)
+			}
+			body = map[string]any{"data": map[string]any{"items": page(start)}, "meta": map[string]any{"next": next}}
+		case "/offset":
+			body = page(from(r, "offset"))
+		case "/link":
+			start := from(r, "page") * size
+			if start+size < total {
+				w.Header().Add("Link", `</first>; rel="first"`)
+				w.Header().Add("Link", fmt.Sprintf(`</link?page=%d>; rel="next"`, start/size+1))
+			}
+			body = page(start)
+		}
+
+		fmt.Fprint(w, compactJSON(body))
+	}))
+}
+
+func TestPaginate(t *testing.T) {
+	model, err := LoadV3([]byte(paginateSpec))
+	assert.NoError(t, err)
+
+	var requests []string
+	server := pagesServer(t, &requests)
+	defer server.Close()
+
+	paginated := func(path string) HandlerData {
+		op := model.Model.Paths.PathItems.GetOrZero(path).Get
+		exts, err := parseExtensions(op.Extensions)
+		assert.NoError(t, err)
+
+		pagination, err := makePagination(op, exts)
+		assert.NoError(t, err)
+
+		return HandlerData{
+			Output:      JSON,
+			Pagination:  pagination,
+			AllPages:    true,
+			Credentials: []Credential{{Type: APIKey, In: "header", Name: "X-API-Key", Value: "s3cret"}},
+		}
+	}
+	send := func(data HandlerData, uri string) string {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+uri, nil)
+		assert.NoError(t, err)
+
+		var out bytes.Buffer
+		assert.NoError(t, data.Send(&out, req))
+
+		return out.String()
+	}
+	all := `[{"id":0},{"id":1},{"id":2},{"id":3},{"id":4},{"id":5},{"id":6}]` + "\n"
+
+	data := paginated("/cursor")
+	assert.Equal(t, []Column{{Header: "id", Path: "$.id"}}, data.Pagination.columns)
+	assert.Equal(t, all, send(data, "/cursor"))
+	assert.Equal(t, []string{"/cursor", "/cursor?after=3", "/cursor?after=6"}, requests)
+
+	requests = nil
+	assert.Equal(t, all, send(paginated("/offset"), "/offset?limit=3"))
+	assert.Equal(t, []string{"/offset?limit=3", "/offset?limit=3&offset=3", "/offset?limit=3&offset=6"}, requests)
+
+	requests = nil
+	assert.Equal(t, all, send(paginated("/link"), "/link"))
+	assert.Equal(t, []string{"/link", "/link?page=1", "/link?page=2"}, requests)
+
+	// Only the pages needed for the items are requested
+	requests = nil
+	data = paginated("/cursor")
+	data.AllPages, data.MaxItems = false, 4
+	assert.Equal(t, `[{"id":0},{"id":1},{"id":2},{"id":3}]`+"\n", send(data, "/cursor"))
+	assert.Len(t, requests, 2)
+
+	data.Output = Table
+	assert.Equal(t, "ID\n0\n1\n2\n3\n", send(data, "/cursor"))
+
+	data.Output, data.Query = YAML, "$[-1].id"
+	assert.Equal(t, "3\n", send(data, "/cursor"))
+
+	// Without --all or --max-items only the page is rendered as is
+	requests = nil
+	data = paginated("/offset")
+	data.AllPages = false
+	assert.Equal(t, `[{"id":0},{"id":1},{"id":2}]`+"\n", send(data, "/offset?limit=3"))
+	assert.Len(t, requests, 1)
+
+	op := model.Model.Paths.PathItems.GetOrZero("/broken").Get
+	exts, err := parseExtensions(op.Extensions)
+	assert.NoError(t, err)
+	_, err = makePagination(op, exts)
+	assert.ErrorContains(t, err, "Invalid x-cli-pagination in Broken: cursor-param and next-cursor are needed")
+}
+
+func TestPaginateToAnotherOrigin(t *testing.T) {
+	model, err := LoadV3([]byte(paginateSpec))
+	assert.NoError(t, err)
+
+	op := model.Model.Paths.PathItems.GetOrZero("/link").Get
+	exts, err := parseExtensions(op.Extensions)
+	assert.NoError(t, err)
+	pagination, err := makePagination(op, exts)
+	assert.NoError(t, err)
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"id":1}]`)
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer s3cret", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Link", fmt.Sprintf(`<%s/link?page=1>; rel="next"`, other.URL))
+		fmt.Fprint(w, `[{"id":0}]`)
+	}))
+	defer server.Close()
+
+	data := HandlerData{
+		Server:      server.URL,
+		Output:      JSON,
+		Pagination:  pagination,
+		AllPages:    true,
+		Credentials: []Credential{{Type: Bearer, Value: "s3cret"}},
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/link", nil)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, data.Send(&out, req))
+	assert.Equal(t, `[{"id":0},{"id":1}]`+"\n", out.String())
+}
+
+func TestPaginateFailure(t *testing.T) {
+	model, err := LoadV3([]byte(paginateSpec))
+	assert.NoError(t, err)
+
+	op := model.Model.Paths.PathItems.GetOrZero("/link").Get
+	exts, err := parseExtensions(op.Extensions)
+	assert.NoError(t, err)
+	pagination, err := makePagination(op, exts)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Has("page") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Link", `</link?page=1>; rel="next"`)
+		fmt.Fprint(w, `[{"id":0}]`)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/link", nil)
+	assert.NoError(t, err)
+
+	// The items of the pages before the failed one are still a JSON array
+	var out bytes.Buffer
+	err = HandlerData{Output: JSON, Pagination: pagination, AllPages: true}.Send(&out, req)
+	var httpErr *HTTPError
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, 500, httpErr.Status)
+	assert.JSONEq(t, `[{"id":0}]`, out.String())
+}
+
+func TestNextLink(t *testing.T) {
+	assert.Equal(t, "/items?page=2", nextLink([]string{`</items?page=1>; rel="prev", </items?page=2>; rel="next"`}))
+	assert.Equal(t, "https://x.io/2", nextLink([]string{`<https://x.io/1>; rel=first`, `<https://x.io/2>; rel="next last"`}))
+	assert.Empty(t, nextLink([]string{`</items?page=1>; rel="prev"`}))
+	assert.Empty(t, nextLink(nil))
+}
+
+func TestStream(t *testing.T) {
+	streamed := func(r renderer, pages ...[]any) string {
+		var out bytes.Buffer
+		s, err := r.stream(&out)
+		assert.NoError(t, err)
+
+		for _, page := range pages {
+			assert.NoError(t, s.write(page))
+		}
+		assert.NoError(t, s.close())
+
+		return out.String()
+	}
+	a, b := map[string]any{"name": "a", "size": 1}, map[string]any{"name": "b<c>", "size": 22, "extra": true}
+
+	assert.Equal(t, `[{"name":"a","size":1},{"extra":true,"name":"b<c>","size":22}]`+"\n", streamed(renderer{format: JSON}, []any{a}, nil, []any{b}))
+	assert.Equal(t, "[]\n", streamed(renderer{format: JSON}))
+
+	t.Setenv("NO_COLOR", "1")
+	assert.Equal(
+		t,
+		"[\n  {\n    \"name\": \"a\",\n    \"size\": 1\n  },\n  {\n    \"extra\": true,\n    \"name\": \"b<c>\",\n    \"size\": 22\n  }\n]\n",
+		streamed(renderer{format: JSON, tty: true}, []any{a}, []any{b}),
+	)
+	assert.Equal(t, "- name: a\n  size: 1\n- extra: true\n  name: b<c>\n  size: 22\n", streamed(renderer{format: YAML}, []any{a}, []any{b}))
+	assert.Equal(t, "[]\n", streamed(renderer{format: YAML}))
+	assert.Equal(t, `{"name":"a","size":1}`+"\n"+`{"extra":true,"name":"b<c>","size":22}`+"\n", streamed(renderer{format: Raw}, []any{a}, []any{b}))
+	assert.Equal(t, "NAME   SIZE\na      1\nNAME   SIZE\n", streamed(renderer{format: Table}, []any{a}, []any{map[string]any{"name": "NAME", "size": "SIZE"}}))
+	assert.Equal(
+		t,
+		"NAME   SIZE\na      1\nb<c>   22\nlon…   333\n",
+		streamed(renderer{format: Table}, []any{a}, []any{b}, []any{map[string]any{"name": "longer", "size": 333}}),
+	)
+	assert.Equal(t, "name,size\na,1\nb<c>,22\n", streamed(renderer{format: CSV}, []any{a}, []any{b}))
+
+	// Queries select across all the items, the columns are for the unfiltered ones
+	columns := []Column{{Header: "name", Path: "$.name"}}
+	assert.Equal(t, "EXTRA   NAME   SIZE\ntrue    b<c>   22\n", streamed(renderer{format: Table, columns: columns, query: "$[?(@.extra)]"}, []any{a}, []any{b}))
+
+	_, err := renderer{format: "xml"}.stream(&bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"go.yaml.in/yaml/v4"
+)
+
+const (
+	profileFlag  = "profile"
+	profileUsage = "The profile of the config file to use"
+)
+
+// A named set of defaults in the config file
+type profile struct {
+	name            string
+	Server          string            `yaml:"server"`           // the server URL, defaults to the first one of the spec
+	ServerVariables map[string]string `yaml:"server-variables"` // values for the variables in the server URL
+	AuthScheme      string            `yaml:"auth-scheme"`      // the security scheme to prefer
+	Flags           map[string]string `yaml:"flags"`            // default values of flags, by name
+}
+
+// The config file, eg:
+//
+//	profile: dev
+//	profiles:
+//	  dev:
+//	    server: https://dev.example.com
+//	    flags:
+//	      api-key: dev-key
+type config struct {
+	Profile  string             `yaml:"profile"` // used when none is selected
+	Profiles map[string]profile `yaml:"profiles"`
+}
+
+// The config file is kept per root command in the user's config dir
+func defaultConfigPath(rootName string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, rootName, "config.yaml")
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("Cannot read config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Selects a profile by name, the one set in the config file if empty.
+// The default profile needn't be in the config file.
+func (o *options) selectProfile(name string) (*profile, error) {
+	cfg, err := loadConfig(o.configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = cfg.Profile
+	}
+
+	if name == "" {
+		name = defaultProfile
+	}
+
+	p, ok := cfg.Profiles[name]
+	if !ok && name != defaultProfile {
+		names := slices.Sorted(maps.Keys(cfg.Profiles))
+
+		return nil, fmt.Errorf("Unknown profile %s, choose one of: %s", name, strings.Join(names, ", "))
+	}
+	p.name = name
+
+	return &p, nil
+}
+
+// The base URL of the server with the variables filled in from the profile or their defaults.
+// The server of the profile takes precedence over the first one of the spec.
+func (p *profile) serverURL(model *v3.Document) string {
+	url := p.Server
+	var server *v3.Server
+
+	for _, s := range model.Servers {
+		if url == "" || s.URL == url {
+			url = s.URL
+			server = s
+			break
+		}
+	}
+
+	for name, value := range p.ServerVariables {
+		url = strings.ReplaceAll(url, "{"+name+"}", value)
+	}
+
+	if server != nil && server.Variables != nil {
+		for name, variable := range server.Variables.FromOldest() {
+			url = strings.ReplaceAll(url, "{"+name+"}", variable.Default)
+		}
+	}
+
+	return url
+}
+
+type profileKey struct{}
+
+func withProfile(ctx context.Context, p *profile) context.Context {
+	return context.WithValue(ctx, profileKey{}, p)
+}
+
+// The profile selected for the command, the default one if none was
+func profileFrom(ctx context.Context) *profile {
+	if p, ok := ctx.Value(profileKey{}).(*profile); ok {
+		return p
+	}
+
+	return &profile{name: defaultProfile}
+}
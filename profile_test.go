@@ -0,0 +1,128 @@
+package climate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const profileSpec = `
+openapi: "3.0.0"
+info:
+  title: Profiles
+  version: "0.1.0"
+servers:
+  - url: https://{env}.example.com/{version}
+    variables:
+      env:
+        default: prod
+      version:
+        default: v1
+  - url: http://localhost:{port}
+    variables:
+      port:
+        default: "8080"
+security:
+  - keyAuth: []
+  - bearerAuth: []
+paths:
+  "/items":
+    get:
+      operationId: ListItems
+      parameters:
+        - name: owner
+          in: query
+          required: true
+          schema:
+            type: string
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            default: 10
+        - name: sort
+          in: query
+          x-cli-env: ITEMS_SORT
+          schema:
+            type: string
+            default: name
+components:
+  securitySchemes:
+    keyAuth:
+      type: apiKey
+      in: header
+      name: X-API-Key
+    bearerAuth:
+      type: http
+      scheme: bearer
+`
+
+const profileConfig = `
+profile: dev
+profiles:
+  dev:
+    server-variables:
+      env: dev
+    auth-scheme: bearerAuth
+    flags:
+      owner: me
+      limit: 50
+      api-key: dev-key
+      token: dev-token
+  local:
+    server: http://localhost:{port}
+    server-variables:
+      port: 9000
+`
+
+// Writes the config to a temp file, returning its path
+func writeConfig(t *testing.T, config string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+
+	return path
+}
+
+func TestSelectProfile(t *testing.T) {
+	o := makeOptions("profiles", []Option{WithConfigFile(writeConfig(t, profileConfig))})
+
+	p, err := o.selectProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, "dev", p.name)
+	assert.Equal(t, "bearerAuth", p.AuthScheme)
+	assert.Equal(t, map[string]string{"owner": "me", "limit": "50", "api-key": "dev-key", "token": "dev-token"}, p.Flags)
+
+	p, err = o.selectProfile("local")
+	assert.NoError(t, err)
+	assert.Equal(t, "local", p.name)
+	assert.Equal(t, map[string]string{"port": "9000"}, p.ServerVariables)
+
+	_, err = o.selectProfile("prod")
+	assert.ErrorContains(t, err, "Unknown profile prod, choose one of: dev, local")
+
+	o = makeOptions("profiles", []Option{WithConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))})
+	p, err = o.selectProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, &profile{name: defaultProfile}, p)
+
+	o = makeOptions("profiles", []Option{WithConfigFile(writeConfig(t, "profiles: [nope]"))})
+	_, err = o.selectProfile("")
+	assert.ErrorContains(t, err, "Cannot read config file")
+}
+
+func TestServerURL(t *testing.T) {
+	model, err := LoadV3([]byte(profileSpec))
+	assert.NoError(t, err)
+
+	doc := &model.Model
+	assert.Equal(t, "https://prod.example.com/v1", (&profile{}).serverURL(doc))
+	assert.Equal(
+		t,
+		"https://dev.example.com/v1",
+		(&profile{ServerVariables: map[string]string{"env": "dev"}}).serverURL(doc),
+	)
+	assert.Equal(t, "http://localhost:8080", (&profile{Server: "http://localhost:{port}"}).serverURL(doc))
+	assert.Equal(t, "https://api.example.org", (&profile{Server: "https://api.example.org"}).serverURL(doc))
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pb33f/jsonpath/pkg/jsonpath"
+	"go.yaml.in/yaml/v4"
+)
+
+const (
+	queryFlag  = "query"
+	queryUsage = "A JSONPath selecting what to show from the response, eg $.items[*].name"
+)
+
+var quoted = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+
+func parseQuery(query string) (*jsonpath.JSONPath, error) {
+	path, err := jsonpath.NewPath(query)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid query %s: %w", query, err)
+	}
+
+	return path, nil
+}
+
+// Singular queries select at most one value, ie the ones without wildcards, filters, slices, unions or descendants
+func isSingular(query string) bool {
+	query = quoted.ReplaceAllString(query, "''")
+
+	return !strings.ContainsAny(query, "*?:,") && !strings.Contains(query, "..")
+}
+
+// Selects from a decoded response, the value for singular queries and the list of values otherwise
+func applyQuery(value any, query string) (any, error) {
+	path, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := toNode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []any{}
+	for _, result := range path.Query(node) {
+		v, err := fromNode(result)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, v)
+	}
+
+	if !isSingular(query) {
+		return selected, nil
+	}
+
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+	return selected[0], nil
+}
+
+func toNode(value any) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(yamlNumbers(value)); err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
+func fromNode(node *yaml.Node) (any, error) {
+	value, err := decodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeJSON(value)
+}
@@ -0,0 +1,61 @@
+package climate
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSingular(t *testing.T) {
+	for _, query := range []string{"$", "$.items[0].name", "$['a*b']", `$["x,y"]`} {
+		assert.Truef(t, isSingular(query), "Query: %s", query)
+	}
+
+	for _, query := range []string{"$.items[*]", "$..name", "$.items[0:2]", "$.items[0,1]", "$.items[?(@.age > 1)]"} {
+		assert.Falsef(t, isSingular(query), "Query: %s", query)
+	}
+}
+
+func TestApplyQuery(t *testing.T) {
+	var value any
+	assert.NoError(t, decodeJSON([]byte(`{"items": [{"name": "rex", "age": 3}, {"name": "tom", "age": 1}]}`), &value))
+
+	selected, err := applyQuery(value, "$.items[0].name")
+	assert.NoError(t, err)
+	assert.Equal(t, "rex", selected)
+
+	selected, err = applyQuery(value, "$.items[?(@.age > 2)]")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{"name": "rex", "age": json.Number("3")}}, selected)
+
+	selected, err = applyQuery(value, "$.items[*].missing")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{}, selected)
+
+	selected, err = applyQuery(value, "$.missing")
+	assert.NoError(t, err)
+	assert.Nil(t, selected)
+
+	_, err = applyQuery(value, "$.items[")
+	assert.ErrorContains(t, err, "Invalid query $.items[")
+}
+
+func TestRenderQuery(t *testing.T) {
+	data := HandlerData{
+		Output:  Table,
+		Columns: []Column{{Header: "name", Path: "$.name"}},
+		Query:   "$.items[*]",
+	}
+	resp := []byte(`{"items": [{"name": "rex", "age": 3}]}`)
+
+	var out bytes.Buffer
+	assert.NoError(t, data.Render(&out, resp))
+	assert.Equal(t, "AGE   NAME\n3     rex\n", out.String())
+
+	out.Reset()
+	data.Output, data.Query = Raw, "$.items[0].name"
+	assert.NoError(t, data.Render(&out, resp))
+	assert.Equal(t, `"rex"`+"\n", out.String())
+}
@@ -0,0 +1,286 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+const (
+	timeoutFlag  = "timeout"
+	timeoutUsage = "Give up on the command after this long, eg 30s. No limit if unset"
+)
+
+// The context of running a command: cancelled on Ctrl-C or when the --timeout passes.
+// Ctrl-C is only caught once, so that a handler ignoring the context can still be interrupted by pressing it again.
+func commandContext(parent context.Context, timeout string) (context.Context, context.CancelFunc, error) {
+	var limit time.Duration
+	if timeout != "" {
+		var err error
+		if limit, err = time.ParseDuration(timeout); err != nil || limit <= 0 {
+			return nil, nil, fmt.Errorf("Invalid timeout %s, use a positive duration like 30s", timeout)
+		}
+	}
+
+	sigCtx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCtx.Done()
+		stop()
+	}()
+
+	if limit == 0 {
+		return sigCtx, stop, nil
+	}
+
+	ctx, cancel := context.WithTimeoutCause(sigCtx, limit, &TimeoutError{Timeout: limit})
+
+	return ctx, func() { cancel(); stop() }, nil
+}
+
+// The error of a command running out of its --timeout, it's a context.DeadlineExceeded too
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Timed out after %s", e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// Tells the timeout apart from other errors of a command run with ctx, for the ones not reporting the cause
+func commandError(ctx context.Context, err error) error {
+	var timeoutErr *TimeoutError
+	if err == nil || errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	if cause := context.Cause(ctx); errors.As(cause, &timeoutErr) {
+		return fmt.Errorf("%w: %w", cause, err)
+	}
+
+	return err
+}
+
+// Builds a request of the operation to the server, with the query, header and cookie params set from the flags.
+// Params left unset without a default are omitted.
+func (h HandlerData) NewRequest(ctx context.Context, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(h.Method), strings.TrimSuffix(h.Server, "/")+h.Path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Streaming != "" {
+		req.Header.Set("Accept", h.Streaming)
+	}
+
+	if h.param == nil {
+		return req, nil
+	}
+
+	query := req.URL.Query()
+	for _, param := range h.QueryParams {
+		if value, ok := h.param(param.Name); ok {
+			query.Set(param.Name, value)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+
+	for _, param := range h.HeaderParams {
+		if value, ok := h.param(param.Name); ok {
+			req.Header.Set(param.Name, value)
+		}
+	}
+
+	for _, param := range h.CookieParams {
+		if value, ok := h.param(param.Name); ok {
+			req.AddCookie(&http.Cookie{Name: param.Name, Value: value})
+		}
+	}
+
+	return req, nil
+}
+
+// Sends a request with the credentials of the operation using the client set via WithHTTPClient.
+// Failures are retried as set in Retry. The request itself is left as is.
+// With DryRun or Curl, it's printed with the secrets masked instead and ErrDryRun is returned.
+// With Verbose, the requests and responses are logged with the secrets masked.
+// With --record, they're added to a HAR file with the secrets masked, and with --replay, answered from one instead.
+func (h HandlerData) Do(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	h.Authorize(req)
+
+	if h.DryRun || h.Curl {
+		return nil, h.printRequest(req)
+	}
+
+	client := h.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if h.archive != nil {
+		client = h.archived(client)
+	}
+
+	if h.Verbose > 0 {
+		client = h.traced(client)
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	if h.Retry != nil {
+		resp, err = h.Retry.do(client, req)
+	} else {
+		resp, err = client.Do(req)
+	}
+
+	if err == nil && h.response != nil && resp.StatusCode < 300 {
+		resp.Body = h.response.capture(resp.Body)
+	}
+
+	return resp, err
+}
+
+// A copy of req to send again, with its body read again if any
+func replayRequest(req *http.Request) (*http.Request, error) {
+	next := req.Clone(req.Context())
+
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("Cannot send the body of %s again", req.URL)
+		}
+
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+
+	return next, nil
+}
+
+// The origin the credentials of the operation are sent to: the server's, or the one of the first request without it
+func (h HandlerData) origin(first *url.URL) *url.URL {
+	if server, err := url.Parse(h.Server); err == nil && server.Host != "" {
+		return server
+	}
+
+	return first
+}
+
+// A copy sending the request to u without the credentials when it's on another origin, eg a page from a Link header
+func (h HandlerData) following(origin, u *url.URL) HandlerData {
+	if !sameOrigin(origin, u) {
+		h.Credentials = nil
+	}
+
+	return h
+}
+
+// Whether the URLs have the same scheme, host and port, the default ones of the scheme included
+func sameOrigin(a, b *url.URL) bool {
+	port := func(u *url.URL) string {
+		if p := u.Port(); p != "" {
+			return p
+		}
+
+		if strings.EqualFold(u.Scheme, "https") {
+			return "443"
+		}
+
+		return "80"
+	}
+
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Hostname(), b.Hostname()) && port(a) == port(b)
+}
+
+// Sends a request and renders the response like Render.
+// For paginated operations with --all or --max-items, the next pages are requested and their items rendered as they arrive.
+// For long-running operations without --no-wait, a 202 Accepted is polled until the operation ends and the final resource is rendered.
+// Event streams and newline delimited JSON are rendered an event or record at a time as they arrive.
+// Binary responses are written to a file as set in OutputFile.
+func (h HandlerData) Send(w io.Writer, req *http.Request) error {
+	if h.Pagination != nil && (h.AllPages || h.MaxItems > 0) {
+		return h.paginate(w, req)
+	}
+
+	resp, err := h.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); resp.StatusCode < 300 {
+		switch {
+		case mediaType == binaryMediaType || h.Binary && !isJSONMediaType(mediaType) && !strings.HasPrefix(mediaType, "text/"):
+			return h.download(w, req, resp)
+		case mediaType == eventStreamMediaType:
+			return h.streamEvents(w, req, resp)
+		case slices.Contains(ndjsonMediaTypes, mediaType):
+			return h.streamRecords(w, resp)
+		}
+	}
+
+	if h.LongRunning != nil && !h.NoWait && resp.StatusCode == http.StatusAccepted {
+		final, err := h.await(req, resp)
+		if err != nil {
+			return err
+		}
+
+		// The final resource comes from another endpoint than the operation
+		if final != resp {
+			h.responses = nil
+		}
+		resp = final
+	}
+
+	return h.Render(w, resp)
+}
+
+// The media types of the responses Send streams
+var streamingMediaTypes = append([]string{eventStreamMediaType}, ndjsonMediaTypes...)
+
+// The streamed media type of the success responses of an operation, empty if they aren't streamed
+func streamingMediaType(op *v3.Operation) string {
+	if op.Responses == nil || op.Responses.Codes == nil {
+		return ""
+	}
+
+	for code, resp := range op.Responses.Codes.FromOldest() {
+		if !strings.HasPrefix(code, "2") || resp == nil || resp.Content == nil {
+			continue
+		}
+
+		for mediaType := range resp.Content.KeysFromOldest() {
+			if slices.Contains(streamingMediaTypes, mediaType) {
+				return mediaType
+			}
+		}
+	}
+
+	return ""
+}
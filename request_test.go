@@ -0,0 +1,110 @@
+package climate
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRequest(t *testing.T) {
+	values := map[string]string{"limit": "10", "X-Trace": "abc", "session": "s1"}
+	data := HandlerData{
+		Method:       "get",
+		Server:       "https://api.example.com/v1/",
+		Path:         "/items",
+		QueryParams:  []ParamMeta{{Name: "limit", Type: Integer}, {Name: "owner", Type: String}},
+		HeaderParams: []ParamMeta{{Name: "X-Trace", Type: String}},
+		CookieParams: []ParamMeta{{Name: "session", Type: String}},
+		param: func(name string) (string, bool) {
+			value, ok := values[name]
+			return value, ok
+		},
+	}
+
+	req, err := data.NewRequest(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", req.Method)
+	assert.Equal(t, "https://api.example.com/v1/items?limit=10", req.URL.String())
+	assert.Equal(t, "abc", req.Header.Get("X-Trace"))
+
+	cookie, err := req.Cookie("session")
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", cookie.Value)
+
+	// Outside of a command only the URL is set
+	data.param = nil
+	req, err = data.NewRequest(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/v1/items", req.URL.String())
+	assert.Empty(t, req.Header)
+}
+
+func TestCommandContext(t *testing.T) {
+	_, _, err := commandContext(context.Background(), "soon")
+	assert.EqualError(t, err, "Invalid timeout soon, use a positive duration like 30s")
+
+	ctx, cancel, err := commandContext(context.Background(), "")
+	assert.NoError(t, err)
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	ctx, cancel, err = commandContext(context.Background(), "10ms")
+	assert.NoError(t, err)
+	defer cancel()
+
+	<-ctx.Done()
+	assert.ErrorIs(t, context.Cause(ctx), context.DeadlineExceeded)
+
+	// Errors not reporting the cause get it
+	err = commandError(ctx, ctx.Err())
+	assert.EqualError(t, err, "Timed out after 10ms: context deadline exceeded")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, context.Cause(ctx), commandError(ctx, context.Cause(ctx)))
+
+	other := errors.New("Not found")
+	assert.Equal(t, other, commandError(ctx, other))
+}
+
+func TestCommandContextInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Interrupts can't be sent to the process itself")
+	}
+
+	ctx, cancel, err := commandContext(context.Background(), "")
+	assert.NoError(t, err)
+	defer cancel()
+
+	p, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, p.Signal(os.Interrupt))
+
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Not cancelled on interrupt")
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	parse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		assert.NoError(t, err)
+
+		return u
+	}
+
+	assert.True(t, sameOrigin(parse("https://api.example.com/v1"), parse("https://API.example.com:443/items?page=2")))
+	assert.True(t, sameOrigin(parse("http://localhost:8080"), parse("http://localhost:8080/items")))
+	assert.False(t, sameOrigin(parse("https://api.example.com"), parse("http://api.example.com/items")))
+	assert.False(t, sameOrigin(parse("https://api.example.com"), parse("https://cdn.example.com/items")))
+	assert.False(t, sameOrigin(parse("http://localhost:8080"), parse("http://localhost:9090/items")))
+}
@@ -0,0 +1,189 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+// How failed requests are retried, set via WithRetry and per operation via x-cli-retry
+type Retry struct {
+	Attempts   int    `yaml:"attempts"`    // in total including the first one, 1 turns retrying off
+	Backoff    string `yaml:"backoff"`     // the delay before the first retry, doubled for each one with jitter
+	MaxBackoff string `yaml:"max-backoff"` // the longest delay unless the server asks for more with Retry-After
+	Statuses   []int  `yaml:"statuses"`    // the statuses to retry, failing to get a response always is
+
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// Used unless set via WithRetry
+var DefaultRetry = Retry{
+	Attempts:   3,
+	Backoff:    "500ms",
+	MaxBackoff: "30s",
+	Statuses:   []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+// Methods which are safe to send again, the only ones retried unless set via x-cli-retry
+var idempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete}
+
+// The retrying of an operation: the one of the options for idempotent methods, overridden by x-cli-retry.
+// Nil when it's not retried.
+func makeRetry(method string, op *v3.Operation, exts *extensions, retry Retry) (*Retry, error) {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("Invalid retry of %s: %s", op.OperationId, fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case exts.retryOff:
+		return nil, nil
+	case exts.retry != nil:
+		r := *exts.retry
+		if r.Attempts == 0 {
+			r.Attempts = retry.Attempts
+		}
+		if r.Backoff == "" {
+			r.Backoff = retry.Backoff
+		}
+		if r.MaxBackoff == "" {
+			r.MaxBackoff = retry.MaxBackoff
+		}
+		if r.Statuses == nil {
+			r.Statuses = retry.Statuses
+		}
+		retry = r
+
+		invalid = func(format string, args ...any) error {
+			return fmt.Errorf("Invalid x-cli-retry in %s: %s", op.OperationId, fmt.Sprintf(format, args...))
+		}
+	case !slices.Contains(idempotentMethods, strings.ToUpper(method)):
+		return nil, nil
+	}
+
+	for _, d := range []struct {
+		value    string
+		duration *time.Duration
+	}{
+		{retry.Backoff, &retry.backoff},
+		{retry.MaxBackoff, &retry.maxBackoff},
+	} {
+		*d.duration = 0
+		if d.value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, invalid("%s is not a duration", d.value)
+		}
+		*d.duration = duration
+	}
+
+	if retry.Attempts < 0 || retry.backoff < 0 || retry.maxBackoff < 0 {
+		return nil, invalid("attempts, backoff and max-backoff can't be negative")
+	}
+
+	if retry.Attempts <= 1 {
+		return nil, nil
+	}
+
+	return &retry, nil
+}
+
+// Sends req until it gets a response which isn't retried or runs out of attempts.
+// Requests with a body are sent again only when it can be got again.
+func (r *Retry) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+
+		last := attempt >= r.Attempts || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil)
+		if last || !r.retryable(resp, err) {
+			return resp, err
+		}
+
+		delay := r.delay(attempt)
+		if resp != nil {
+			delay = retryAfter(resp.Header, delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func (r *Retry) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return transientError(err)
+	}
+
+	return slices.Contains(r.Statuses, resp.StatusCode)
+}
+
+// Whether a request failed without a response because of the network, eg the connection being reset.
+// Errors of building or cancelling the request, or of the client's CheckRedirect, aren't.
+func transientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// A *url.Error is a net.Error whatever its cause
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	// The transport fails with io.EOF when the server closes the connection before responding
+	var netErr net.Error
+
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// The backoff before the retry after an attempt, between half and all of it
+func (r *Retry) delay(attempt int) time.Duration {
+	backoff := r.backoff
+	for i := 1; i < attempt && (r.maxBackoff == 0 || backoff < r.maxBackoff); i++ {
+		backoff *= 2
+	}
+	if r.maxBackoff > 0 {
+		backoff = min(backoff, r.maxBackoff)
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	return backoff/2 + rand.N(backoff/2+1)
+}
@@ -0,0 +1,245 @@
+package climate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const retrySpec = `
+openapi: "3.0.0"
+info:
+  title: Retries
+  version: "0.1.0"
+paths:
+  "/items":
+    get:
+      operationId: ListItems
+      responses:
+        "200":
+          description: The items
+    post:
+      operationId: CreateItem
+      responses:
+        "201":
+          description: Created
+    put:
+      operationId: ReplaceItems
+      x-cli-retry: false
+      responses:
+        "200":
+          description: Replaced
+  "/orders":
+    post:
+      operationId: CreateOrder
+      x-cli-retry: true
+      responses:
+        "201":
+          description: Created
+    delete:
+      operationId: DeleteOrders
+      x-cli-retry:
+        attempts: 5
+        backoff: 5ms
+      responses:
+        "204":
+          description: Deleted
+    put:
+      operationId: Broken
+      x-cli-retry:
+        attempts: -1
+      responses:
+        "200":
+          description: Replaced
+    patch:
+      operationId: Slow
+      x-cli-retry:
+        backoff: 5
+      responses:
+        "200":
+          description: Updated
+`
+
+// Fails the first requests in turn with each of the failures, then succeeds echoing the body
+func flakyServer(t *testing.T, failures ...func(w http.ResponseWriter)) (*httptest.Server, *int) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "s3cret", r.Header.Get("X-API-Key"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		requests++
+		if requests <= len(failures) {
+			failures[requests-1](w)
+			return
+		}
+
+		w.Write(body)
+	})), &requests
+}
+
+func unavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "0")
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+func tooMany(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+func reset(w http.ResponseWriter) {
+	conn, _, _ := w.(http.Hijacker).Hijack()
+	conn.Close()
+}
+
+func TestRetry(t *testing.T) {
+	retry := &Retry{Attempts: 3, backoff: time.Millisecond, maxBackoff: 5 * time.Millisecond, Statuses: DefaultRetry.Statuses}
+	data := HandlerData{Retry: retry, Credentials: []Credential{{Type: APIKey, In: "header", Name: "X-API-Key", Value: "s3cret"}}}
+	send := func(server *httptest.Server, method, body string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(context.Background(), method, server.URL, strings.NewReader(body))
+		assert.NoError(t, err)
+
+		return data.Do(req)
+	}
+
+	server, requests := flakyServer(t, unavailable, reset)
+	defer server.Close()
+
+	resp, err := send(server, http.MethodPut, "same")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "same", string(body))
+	assert.Equal(t, 3, *requests)
+
+	// The last response is returned once the attempts run out
+	server, requests = flakyServer(t, tooMany, tooMany, tooMany, tooMany)
+	defer server.Close()
+
+	resp, err = send(server, http.MethodGet, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, 3, *requests)
+
+	// Other statuses aren't retried
+	server, requests = flakyServer(t, func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) })
+	defer server.Close()
+
+	resp, err = send(server, http.MethodGet, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 1, *requests)
+
+	// Neither are bodies which can't be sent again
+	server, requests = flakyServer(t, unavailable)
+	defer server.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, server.URL, io.NopCloser(strings.NewReader("once")))
+	assert.NoError(t, err)
+	resp, err = data.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, *requests)
+
+	// Nor cancelled requests
+	server, requests = flakyServer(t, unavailable)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+	_, err = data.Do(req)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, *requests)
+
+	// Nor the failures not coming from the network
+	assert.True(t, transientError(&url.Error{Op: "Get", URL: "http://x", Err: io.ErrUnexpectedEOF}))
+	assert.True(t, transientError(&url.Error{Op: "Get", URL: "http://x", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}))
+	assert.False(t, transientError(&url.Error{Op: "Get", URL: "http://x", Err: errors.New("stopped after 10 redirects")}))
+	assert.False(t, transientError(&url.Error{Op: "Get", URL: "http://x", Err: context.Canceled}))
+}
+
+func TestRetryDelay(t *testing.T) {
+	r := &Retry{backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second} {
+		for range 10 {
+			delay := r.delay(attempt)
+			assert.GreaterOrEqual(t, delay, want/2)
+			assert.LessOrEqual(t, delay, want)
+		}
+	}
+
+	assert.Equal(t, time.Duration(0), (&Retry{}).delay(3))
+}
+
+func TestMakeRetry(t *testing.T) {
+	model, err := LoadV3([]byte(retrySpec))
+	assert.NoError(t, err)
+
+	retry := func(path, method string) (*Retry, error) {
+		op := model.Model.Paths.PathItems.GetOrZero(path).GetOperations().GetOrZero(method)
+		exts, err := parseExtensions(op.Extensions)
+		assert.NoError(t, err)
+
+		return makeRetry(method, op, exts, DefaultRetry)
+	}
+
+	r, err := retry("/items", "get")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRetry.Attempts, r.Attempts)
+	assert.Equal(t, 500*time.Millisecond, r.backoff)
+	assert.Equal(t, 30*time.Second, r.maxBackoff)
+
+	// Only idempotent methods are retried unless turned on
+	r, err = retry("/items", "post")
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	r, err = retry("/orders", "post")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRetry.Attempts, r.Attempts)
+
+	r, err = retry("/items", "put")
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	r, err = retry("/orders", "delete")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, r.Attempts)
+	assert.Equal(t, 5*time.Millisecond, r.backoff)
+	assert.Equal(t, 30*time.Second, r.maxBackoff)
+
+	_, err = retry("/orders", "put")
+	assert.EqualError(t, err, "Invalid x-cli-retry in Broken: attempts, backoff and max-backoff can't be negative")
+
+	_, err = retry("/orders", "patch")
+	assert.EqualError(t, err, "Invalid x-cli-retry in Slow: 5 is not a duration")
+
+	_, err = makeRetry("get", model.Model.Paths.PathItems.GetOrZero("/items").Get, &extensions{}, Retry{Attempts: 2, Backoff: "-1s"})
+	assert.EqualError(t, err, "Invalid retry of ListItems: attempts, backoff and max-backoff can't be negative")
+
+	// An attempt turns retrying off
+	op := model.Model.Paths.PathItems.GetOrZero("/items").Get
+	r, err = makeRetry("get", op, &extensions{}, Retry{Attempts: 1})
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+}
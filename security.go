@@ -0,0 +1,377 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+// Currently supported kinds of credentials
+type CredentialType string
+
+const (
+	APIKey CredentialType = "apiKey"
+	Basic  CredentialType = "basic"
+	Bearer CredentialType = "bearer"
+)
+
+// A credential resolved for one of the security schemes of an operation
+type Credential struct {
+	Scheme string         // the name of the scheme in components.securitySchemes
+	Type   CredentialType // the kind of the credential
+	In     string         // where an API key is sent: header, query or cookie
+	Name   string         // the name of the header, query param or cookie of an API key
+	Value  string         // the API key, the token or user:password for basic auth
+}
+
+// Root flags used to supply credentials
+const (
+	apiKeyFlag = "api-key"
+	tokenFlag  = "token"
+	userFlag   = "user"
+)
+
+type authFlag struct {
+	name  string
+	usage string
+}
+
+// Attaches the credential to a request
+func (c Credential) Apply(req *http.Request) {
+	switch c.Type {
+	case APIKey:
+		switch c.In {
+		case "header":
+			req.Header.Set(c.Name, c.Value)
+		case "query":
+			query := req.URL.Query()
+			query.Set(c.Name, c.Value)
+			req.URL.RawQuery = query.Encode()
+		case "cookie":
+			req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+		}
+	case Basic:
+		user, password, _ := strings.Cut(c.Value, ":")
+		req.SetBasicAuth(user, password)
+	case Bearer:
+		req.Header.Set("Authorization", "Bearer "+c.Value)
+	}
+}
+
+// Attaches the credentials resolved for the operation to a request
+func (h HandlerData) Authorize(req *http.Request) {
+	for _, cred := range h.Credentials {
+		cred.Apply(req)
+	}
+}
+
+// Maps a security scheme to the kind of credential and the root flags supplying it
+func credentialTypeOf(scheme *v3.SecurityScheme) (CredentialType, []string, bool) {
+	switch strings.ToLower(scheme.Type) {
+	case "apikey":
+		return APIKey, []string{apiKeyFlag}, true
+	case "http":
+		switch strings.ToLower(scheme.Scheme) {
+		case "basic":
+			return Basic, []string{userFlag}, true
+		case "bearer":
+			return Bearer, []string{tokenFlag}, true
+		}
+	case "oauth2":
+		if scheme.Flows != nil && (scheme.Flows.ClientCredentials != nil || loginFlowsOf(scheme).supported()) {
+			return Bearer, []string{clientIDFlag, clientSecretFlag}, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// The root flags needed for the security schemes declared in the spec
+func makeAuthFlags(model *v3.Document) []authFlag {
+	if model.Components == nil || model.Components.SecuritySchemes == nil {
+		return nil
+	}
+
+	usages := map[string]string{
+		apiKeyFlag:       "The API key to authenticate with",
+		tokenFlag:        "The bearer token to authenticate with",
+		userFlag:         "The user:password to authenticate with using basic auth",
+		clientIDFlag:     "The OAuth2 client id to fetch tokens with",
+		clientSecretFlag: "The OAuth2 client secret to fetch tokens with, optional for public clients logging in",
+	}
+	seen := make(map[string]bool)
+	var flags []authFlag
+
+	for name, scheme := range model.Components.SecuritySchemes.FromOldest() {
+		_, schemeFlags, ok := credentialTypeOf(scheme)
+		if !ok {
+			slog.Warn("Unsupported security scheme, skipping", "scheme", name, "type", scheme.Type)
+			continue
+		}
+
+		for _, flag := range schemeFlags {
+			if !seen[flag] {
+				seen[flag] = true
+				flags = append(flags, authFlag{name: flag, usage: usages[flag]})
+			}
+		}
+	}
+
+	return flags
+}
+
+// Converts a flag name to the env var binding it for the root command: api-key -> CALC_API_KEY
+func envVarName(rootName, flag string) string {
+	name := rootName + "_" + flag
+
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name))
+}
+
+// A param carrying a secret, masked when requests are printed or traced
+type secret struct {
+	in   string // header, query or cookie, or body for a property of JSON bodies
+	name string
+}
+
+// The params of an operation carrying secrets: the API keys of the security schemes and the ones in the password format
+func secretsOf(model *v3.Document, op *v3.Operation) []secret {
+	var secrets []secret
+
+	if model.Components != nil && model.Components.SecuritySchemes != nil {
+		for _, scheme := range model.Components.SecuritySchemes.FromOldest() {
+			if strings.EqualFold(scheme.Type, "apiKey") && scheme.Name != "" {
+				secrets = append(secrets, secret{in: scheme.In, name: scheme.Name})
+			}
+		}
+	}
+
+	for _, param := range op.Parameters {
+		if param.Schema == nil {
+			continue
+		}
+
+		if schema := param.Schema.Schema(); schema != nil && schema.Format == "password" {
+			secrets = append(secrets, secret{in: param.In, name: param.Name})
+		}
+	}
+
+	if op.RequestBody != nil && op.RequestBody.Content != nil {
+		for _, media := range op.RequestBody.Content.FromOldest() {
+			passwordProperties(media.Schema, 0, &secrets)
+		}
+	}
+
+	return secrets
+}
+
+// Adds the properties in the password format of a schema and of the ones nested in it
+func passwordProperties(proxy *base.SchemaProxy, depth int, secrets *[]secret) {
+	if proxy == nil || depth > maxSkeletonDepth {
+		return
+	}
+
+	schema := proxy.Schema()
+	if schema == nil {
+		return
+	}
+
+	if schema.Properties != nil {
+		for name, prop := range schema.Properties.FromOldest() {
+			if ps := prop.Schema(); ps != nil && ps.Format == "password" {
+				*secrets = append(*secrets, secret{in: "body", name: name})
+			}
+			passwordProperties(prop, depth+1, secrets)
+		}
+	}
+
+	if schema.Items != nil && schema.Items.IsA() {
+		passwordProperties(schema.Items.A, depth+1, secrets)
+	}
+
+	for _, sub := range slices.Concat(schema.AllOf, schema.OneOf, schema.AnyOf) {
+		passwordProperties(sub, depth+1, secrets)
+	}
+}
+
+// The security requirements of an operation, falling back to the ones of the document
+func securityRequirements(model *v3.Document, op *v3.Operation) []*base.SecurityRequirement {
+	if op.Security != nil {
+		return op.Security
+	}
+
+	return model.Security
+}
+
+// Resolves the credentials of an operation from the root flags
+type credentialResolver struct {
+	model      *v3.Document
+	rootName   string
+	lookup     func(flag string) string // returns the value of a root flag or an empty string if not supplied
+	client     *http.Client             // used to fetch tokens
+	store      CredentialStore          // where the credentials from logging in are kept
+	profile    string                   // the profile to use the stored credentials of
+	authScheme string                   // the scheme to prefer when there are alternatives
+	replay     bool                     // whether the requests are answered from an archive, missing credentials are then placeholders
+}
+
+func newCredentialResolver(
+	model *v3.Document,
+	rootName string,
+	o *options,
+	p *profile,
+	lookup func(string) string,
+) credentialResolver {
+	return credentialResolver{
+		model:      model,
+		rootName:   rootName,
+		lookup:     lookup,
+		client:     o.httpClient,
+		store:      o.credentialStore,
+		profile:    p.name,
+		authScheme: p.AuthScheme,
+	}
+}
+
+// Resolves the credentials for the first of the alternative security requirements that can be satisfied.
+// An empty requirement, as in `security: []`, makes the operation anonymous.
+func (r credentialResolver) resolve(ctx context.Context, op *v3.Operation) ([]Credential, error) {
+	var wanted []string
+
+	reqs := slices.Clone(securityRequirements(r.model, op))
+	if r.authScheme != "" {
+		slices.SortStableFunc(reqs, func(a, b *base.SecurityRequirement) int {
+			return r.rank(a) - r.rank(b)
+		})
+	}
+
+	for _, req := range reqs {
+		if req.ContainsEmptyRequirement || req.Requirements == nil || req.Requirements.Len() == 0 {
+			return nil, nil
+		}
+
+		creds, missing, err := r.satisfy(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(missing) == 0 {
+			return creds, nil
+		}
+
+		wanted = append(wanted, strings.Join(missing, " and "))
+	}
+
+	if len(wanted) > 0 {
+		// The server has the final say, let the request go through without them
+		slog.Warn("Missing credentials", "id", op.OperationId, "set one of", strings.Join(wanted, ", or "))
+	}
+
+	return nil, nil
+}
+
+// Requirements with the preferred scheme rank first
+func (r credentialResolver) rank(req *base.SecurityRequirement) int {
+	if req.Requirements == nil {
+		return 1
+	}
+
+	if _, ok := req.Requirements.Get(r.authScheme); !ok {
+		return 1
+	}
+
+	return 0
+}
+
+// The stored value of a scheme if any and not expired
+func (r credentialResolver) storedValue(scheme string) string {
+	stored, err := r.store.Get(r.profile, scheme)
+	if err != nil {
+		slog.Warn("Cannot read the stored credentials", "error", err)
+
+		return ""
+	}
+
+	if !stored.valid() {
+		return ""
+	}
+
+	return stored.Value
+}
+
+// Tries to satisfy all the schemes of a requirement, returning the flags needed otherwise
+func (r credentialResolver) satisfy(ctx context.Context, req *base.SecurityRequirement) ([]Credential, []string, error) {
+	var (
+		creds   []Credential
+		missing []string
+	)
+
+	for name, scopes := range req.Requirements.FromOldest() {
+		var scheme *v3.SecurityScheme
+		if r.model.Components != nil && r.model.Components.SecuritySchemes != nil {
+			scheme = r.model.Components.SecuritySchemes.GetOrZero(name)
+		}
+
+		if scheme == nil {
+			missing = append(missing, fmt.Sprintf("undefined scheme %s", name))
+			continue
+		}
+
+		typ, flags, ok := credentialTypeOf(scheme)
+		if !ok {
+			missing = append(missing, fmt.Sprintf("unsupported scheme %s", name))
+			continue
+		}
+
+		var value string
+		if isOAuth2(scheme) && r.replay {
+			// Nothing is sent when replaying, a token would only be masked in the archive
+			value = masked
+		} else if isOAuth2(scheme) {
+			token, needs, err := r.oauth2Token(ctx, name, scheme, scopes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Cannot get token for %s: %w", name, err)
+			}
+
+			if token == "" {
+				missing = append(missing, needs)
+				continue
+			}
+
+			value = token
+		} else if value = r.lookup(flags[0]); value == "" {
+			value = r.storedValue(name)
+		}
+
+		if value == "" && r.replay {
+			value = masked
+		}
+
+		if value == "" {
+			missing = append(missing, "--"+flags[0])
+			continue
+		}
+
+		creds = append(creds, Credential{
+			Scheme: name,
+			Type:   typ,
+			In:     scheme.In,
+			Name:   scheme.Name,
+			Value:  value,
+		})
+	}
+
+	return creds, missing, nil
+}
@@ -0,0 +1,166 @@
+package climate
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+const securitySpec = `
+openapi: "3.0.0"
+info:
+  title: Secure
+  version: "0.1.0"
+security:
+  - bearerAuth: []
+paths:
+  "/inherited":
+    get:
+      operationId: Inherited
+  "/anonymous":
+    get:
+      operationId: Anonymous
+      security: []
+  "/alternatives":
+    get:
+      operationId: Alternatives
+      security:
+        - keyAuth: []
+        - basicAuth: []
+  "/combined":
+    get:
+      operationId: Combined
+      security:
+        - keyAuth: []
+          bearerAuth: []
+components:
+  securitySchemes:
+    keyAuth:
+      type: apiKey
+      in: header
+      name: X-API-Key
+    basicAuth:
+      type: http
+      scheme: basic
+    bearerAuth:
+      type: http
+      scheme: bearer
+    openId:
+      type: openIdConnect
+      openIdConnectUrl: https://example.com/.well-known/openid-configuration
+`
+
+func TestMakeAuthFlags(t *testing.T) {
+	model, err := LoadV3([]byte(securitySpec))
+	assert.NoError(t, err)
+
+	var names []string
+	for _, flag := range makeAuthFlags(&model.Model) {
+		names = append(names, flag.name)
+	}
+
+	assert.Equal(t, []string{apiKeyFlag, userFlag, tokenFlag}, names)
+}
+
+func TestEnvVarName(t *testing.T) {
+	assert.Equal(t, "CALC_API_KEY", envVarName("calc", "api-key"))
+	assert.Equal(t, "MY_CALC_TOKEN", envVarName("my-calc", "token"))
+}
+
+func TestResolveCredentials(t *testing.T) {
+	model, err := LoadV3([]byte(securitySpec))
+	assert.NoError(t, err)
+
+	doc := &model.Model
+	op := func(path string) *v3.Operation {
+		return doc.Paths.PathItems.GetOrZero(path).Get
+	}
+	lookup := func(values map[string]string) func(string) string {
+		return func(flag string) string { return values[flag] }
+	}
+	resolve := func(op *v3.Operation, lookup func(string) string) []Credential {
+		creds, err := newCredentialResolver(
+			doc,
+			"secure",
+			makeOptions("secure", []Option{WithCredentialStore(NewMemoryStore())}),
+			&profile{name: defaultProfile},
+			lookup,
+		).resolve(context.Background(), op)
+		assert.NoError(t, err)
+
+		return creds
+	}
+	all := lookup(map[string]string{apiKeyFlag: "key", userFlag: "me:secret", tokenFlag: "tok"})
+
+	assert.Equal(
+		t,
+		[]Credential{{Scheme: "bearerAuth", Type: Bearer, Value: "tok"}},
+		resolve(op("/inherited"), all),
+	)
+	assert.Empty(t, resolve(op("/anonymous"), all))
+	assert.Equal(
+		t,
+		[]Credential{{Scheme: "keyAuth", Type: APIKey, In: "header", Name: "X-API-Key", Value: "key"}},
+		resolve(op("/alternatives"), all),
+	)
+	assert.Equal(
+		t,
+		[]Credential{{Scheme: "basicAuth", Type: Basic, Value: "me:secret"}},
+		resolve(op("/alternatives"), lookup(map[string]string{userFlag: "me:secret"})),
+	)
+	assert.Equal(
+		t,
+		[]Credential{
+			{Scheme: "keyAuth", Type: APIKey, In: "header", Name: "X-API-Key", Value: "key"},
+			{Scheme: "bearerAuth", Type: Bearer, Value: "tok"},
+		},
+		resolve(op("/combined"), all),
+	)
+	assert.Empty(t, resolve(op("/combined"), lookup(map[string]string{tokenFlag: "tok"})))
+}
+
+// A resolver with an in memory store and the given root flags set
+func testResolver(model *v3.Document, rootName string, flags map[string]string) credentialResolver {
+	lookup := func(flag string) string { return flags[flag] }
+
+	return newCredentialResolver(
+		model,
+		rootName,
+		makeOptions(rootName, []Option{WithCredentialStore(NewMemoryStore())}),
+		&profile{name: defaultProfile},
+		lookup,
+	)
+}
+
+func TestAuthorize(t *testing.T) {
+	data := HandlerData{
+		Credentials: []Credential{
+			{Type: APIKey, In: "header", Name: "X-API-Key", Value: "hkey"},
+			{Type: APIKey, In: "query", Name: "key", Value: "qkey"},
+			{Type: APIKey, In: "cookie", Name: "session", Value: "ckey"},
+		},
+	}
+	req := httptest.NewRequest("GET", "http://example.com/path?a=b", nil)
+	data.Authorize(req)
+
+	assert.Equal(t, "hkey", req.Header.Get("X-API-Key"))
+	assert.Equal(t, "qkey", req.URL.Query().Get("key"))
+	assert.Equal(t, "b", req.URL.Query().Get("a"))
+	cookie, err := req.Cookie("session")
+	assert.NoError(t, err)
+	assert.Equal(t, "ckey", cookie.Value)
+
+	req = httptest.NewRequest("GET", "http://example.com", nil)
+	Credential{Type: Basic, Value: "me:secret"}.Apply(req)
+	user, password, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "me", user)
+	assert.Equal(t, "secret", password)
+
+	req = httptest.NewRequest("GET", "http://example.com", nil)
+	Credential{Type: Bearer, Value: "tok"}.Apply(req)
+	assert.Equal(t, "Bearer tok", req.Header.Get("Authorization"))
+}
@@ -0,0 +1,313 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"go.yaml.in/yaml/v4"
+)
+
+const skeletonFlag = "generate-skeleton"
+
+const skeletonUsage = "Print a json or yaml template of the input and exit, json if no format is given as in --generate-skeleton=yaml"
+
+// The format of the skeleton when the flag is passed without one
+const defaultSkeletonFormat = "json"
+
+// Limits how deep recursive schemas are expanded
+const maxSkeletonDepth = 8
+
+// Builds an example of the input an operation expects.
+// It's the request body if there's one, otherwise a map of the flag names of the parameters to example values.
+// A body without a schema or examples has none.
+func makeSkeleton(op *v3.Operation, handlerData *HandlerData) (any, error) {
+	if body := op.RequestBody; body != nil {
+		media := preferredMediaType(body.Content)
+		if media == nil || media.Schema == nil && media.Example == nil && orderedmap.Len(media.Examples) == 0 {
+			return nil, fmt.Errorf("Cannot generate a skeleton, %s has no JSON body schema", op.OperationId)
+		}
+
+		return exampleOrSynthesize(media.Example, media.Examples, media.Schema)
+	}
+
+	var flagged []ParamMeta
+	for _, params := range [][]ParamMeta{
+		handlerData.PathParams,
+		handlerData.QueryParams,
+		handlerData.HeaderParams,
+		handlerData.CookieParams,
+	} {
+		flagged = append(flagged, params...)
+	}
+
+	params := make(map[string]any)
+	for _, param := range op.Parameters {
+		if !slices.ContainsFunc(flagged, func(p ParamMeta) bool { return p.Name == param.Name }) {
+			continue
+		}
+
+		value, err := exampleOrSynthesize(param.Example, param.Examples, param.Schema)
+		if err != nil {
+			return nil, err
+		}
+
+		params[param.Name] = value
+	}
+
+	return params, nil
+}
+
+// Writes the skeleton in the requested format: json or yaml
+func writeSkeleton(w io.Writer, skeleton any, format string) error {
+	var (
+		out []byte
+		err error
+	)
+
+	switch strings.ToLower(format) {
+	case "json":
+		out, err = json.MarshalIndent(skeleton, "", "  ")
+		out = append(out, '\n')
+	case "yaml":
+		out, err = yaml.Marshal(skeleton)
+	default:
+		return fmt.Errorf("Unsupported skeleton format %q, expected json or yaml", format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+
+	return err
+}
+
+// Prefers JSON content when there are multiple, falling back to the first declared one
+func preferredMediaType(content *orderedmap.Map[string, *v3.MediaType]) *v3.MediaType {
+	if content == nil {
+		return nil
+	}
+
+	var first *v3.MediaType
+	for mime, media := range content.FromOldest() {
+		if first == nil {
+			first = media
+		}
+
+		if isJSONMediaType(mime) {
+			return media
+		}
+	}
+
+	return first
+}
+
+func isJSONMediaType(mime string) bool {
+	mime, _, _ = strings.Cut(mime, ";")
+	mime = strings.TrimSpace(strings.ToLower(mime))
+
+	return mime == "application/json" || strings.HasSuffix(mime, "+json")
+}
+
+// Uses the example if set, then the first of the named examples and finally the schema
+func exampleOrSynthesize(example *yaml.Node, examples *orderedmap.Map[string, *base.Example], schema *base.SchemaProxy) (any, error) {
+	if example != nil {
+		return decodeNode(example)
+	}
+
+	if examples != nil {
+		for _, ex := range examples.FromOldest() {
+			if ex != nil && ex.Value != nil {
+				return decodeNode(ex.Value)
+			}
+		}
+	}
+
+	return synthesize(schema, 0)
+}
+
+func decodeNode(node *yaml.Node) (any, error) {
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return stringKeys(value), nil
+}
+
+// Converts the keys of the YAML mappings to strings like JSON has them, eg 1: one or true: yes
+func stringKeys(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		converted := make(map[string]any, len(v))
+		for key, val := range v {
+			converted[key] = stringKeys(val)
+		}
+
+		return converted
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, val := range v {
+			converted[fmt.Sprint(key)] = stringKeys(val)
+		}
+
+		return converted
+	case []any:
+		converted := make([]any, len(v))
+		for i, val := range v {
+			converted[i] = stringKeys(val)
+		}
+
+		return converted
+	}
+
+	return value
+}
+
+// Synthesizes a value conforming to the schema, preferring what the spec says about it
+func synthesize(proxy *base.SchemaProxy, depth int) (any, error) {
+	if proxy == nil || depth > maxSkeletonDepth {
+		return nil, nil
+	}
+
+	schema := proxy.Schema()
+	if schema == nil {
+		return nil, proxy.GetBuildError()
+	}
+
+	for _, node := range []*yaml.Node{schema.Example, schema.Const, schema.Default} {
+		if node != nil {
+			return decodeNode(node)
+		}
+	}
+
+	if len(schema.Examples) > 0 {
+		return decodeNode(schema.Examples[0])
+	}
+
+	if len(schema.Enum) > 0 {
+		return decodeNode(schema.Enum[0])
+	}
+
+	if len(schema.AllOf) > 0 {
+		merged := make(map[string]any)
+		for _, sub := range schema.AllOf {
+			value, err := synthesize(sub, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			if m, ok := value.(map[string]any); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+
+		return merged, nil
+	}
+
+	for _, alternatives := range [][]*base.SchemaProxy{schema.OneOf, schema.AnyOf} {
+		if len(alternatives) > 0 {
+			return synthesize(alternatives[0], depth+1)
+		}
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		obj := make(map[string]any)
+		if schema.Properties != nil {
+			for name, prop := range schema.Properties.FromOldest() {
+				if ps := prop.Schema(); ps != nil && ps.ReadOnly != nil && *ps.ReadOnly {
+					continue
+				}
+
+				value, err := synthesize(prop, depth+1)
+				if err != nil {
+					return nil, err
+				}
+
+				obj[name] = value
+			}
+		}
+
+		return obj, nil
+	case "array":
+		if schema.Items == nil || !schema.Items.IsA() {
+			return []any{}, nil
+		}
+
+		item, err := synthesize(schema.Items.A, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		return []any{item}, nil
+	case string(String):
+		return exampleString(schema.Format), nil
+	case string(Integer):
+		return 0, nil
+	case string(Number):
+		return 0.0, nil
+	case string(Boolean):
+		return false, nil
+	}
+
+	return nil, nil
+}
+
+// Picks the first non null type, inferring object or array from the shape if unset
+func schemaType(schema *base.Schema) string {
+	for _, t := range schema.Type {
+		if t != "null" {
+			return t
+		}
+	}
+
+	switch {
+	case schema.Properties != nil && schema.Properties.Len() > 0:
+		return "object"
+	case schema.Items != nil:
+		return "array"
+	}
+
+	return ""
+}
+
+func exampleString(format string) string {
+	examples := map[string]string{
+		"date":      "2025-01-01",
+		"date-time": "2025-01-01T00:00:00Z",
+		"time":      "00:00:00Z",
+		"email":     "user@example.com",
+		"uuid":      "00000000-0000-0000-0000-000000000000",
+		"uri":       "https://example.com",
+		"url":       "https://example.com",
+		"hostname":  "example.com",
+		"ipv4":      "192.0.2.1",
+		"ipv6":      "2001:db8::1",
+		"byte":      "",
+		"binary":    "",
+		"password":  "",
+	}
+
+	if ex, ok := examples[format]; ok {
+		return ex
+	}
+
+	return "string"
+}
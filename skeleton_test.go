@@ -0,0 +1,186 @@
+package climate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const skeletonSpec = `
+openapi: "3.0.0"
+info:
+  title: Skeletons
+  version: "0.1.0"
+paths:
+  "/example":
+    post:
+      operationId: MediaExample
+      requestBody:
+        content:
+          text/plain:
+            schema:
+              type: string
+          application/json:
+            example:
+              name: from-example
+            schema:
+              $ref: "#/components/schemas/Pet"
+  "/examples":
+    post:
+      operationId: MediaExamples
+      requestBody:
+        content:
+          application/json:
+            examples:
+              first:
+                value:
+                  name: first
+              second:
+                value:
+                  name: second
+  "/synthesized":
+    post:
+      operationId: Synthesized
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+  "/untyped":
+    post:
+      operationId: Untyped
+      requestBody:
+        description: No content
+  "/keys":
+    post:
+      operationId: Keys
+      requestBody:
+        content:
+          application/json:
+            example:
+              codes:
+                404: missing
+                true:
+                  - 1.5: x
+  "/params/{id}":
+    get:
+      operationId: Params
+      parameters:
+        - name: id
+          in: path
+          required: true
+          example: 7
+          schema:
+            type: integer
+        - name: q
+          in: query
+          schema:
+            type: string
+            default: everything
+        - name: at
+          in: query
+          schema:
+            type: string
+            format: date-time
+        - name: tags
+          in: query
+          schema:
+            type: array
+            items:
+              type: string
+components:
+  schemas:
+    Pet:
+      allOf:
+        - type: object
+          properties:
+            id:
+              type: integer
+              readOnly: true
+            name:
+              type: string
+        - type: object
+          properties:
+            kind:
+              type: string
+              enum:
+                - cat
+                - dog
+            owner:
+              type: object
+              properties:
+                email:
+                  type: string
+                  format: email
+            weight:
+              type: number
+            vaccinated:
+              type: boolean
+            tags:
+              type: array
+              items:
+                type: string
+            parent:
+              $ref: "#/components/schemas/Pet"
+`
+
+func TestMakeSkeleton(t *testing.T) {
+	model, err := LoadV3([]byte(skeletonSpec))
+	assert.NoError(t, err)
+
+	ops := model.Model.Paths.PathItems
+
+	skeleton, err := makeSkeleton(ops.GetOrZero("/example").Post, &HandlerData{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "from-example"}, skeleton)
+
+	skeleton, err = makeSkeleton(ops.GetOrZero("/examples").Post, &HandlerData{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "first"}, skeleton)
+
+	skeleton, err = makeSkeleton(ops.GetOrZero("/synthesized").Post, &HandlerData{})
+	assert.NoError(t, err)
+	pet := skeleton.(map[string]any)
+	assert.NotContains(t, pet, "id")
+	assert.Equal(t, "string", pet["name"])
+	assert.Equal(t, "cat", pet["kind"])
+	assert.Equal(t, map[string]any{"email": "user@example.com"}, pet["owner"])
+	assert.Equal(t, 0.0, pet["weight"])
+	assert.Equal(t, false, pet["vaccinated"])
+	assert.Equal(t, []any{"string"}, pet["tags"])
+	assert.Contains(t, pet, "parent")
+
+	_, err = makeSkeleton(ops.GetOrZero("/untyped").Post, &HandlerData{})
+	assert.EqualError(t, err, "Cannot generate a skeleton, Untyped has no JSON body schema")
+
+	// JSON only has string keys
+	skeleton, err = makeSkeleton(ops.GetOrZero("/keys").Post, &HandlerData{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"codes": map[string]any{"404": "missing", "true": []any{map[string]any{"1.5": "x"}}}}, skeleton)
+
+	var out bytes.Buffer
+	assert.NoError(t, writeSkeleton(&out, skeleton, "json"))
+	assert.JSONEq(t, `{"codes": {"404": "missing", "true": [{"1.5": "x"}]}}`, out.String())
+
+	skeleton, err = makeSkeleton(ops.GetOrZero("/params/{id}").Get, &HandlerData{
+		PathParams:  []ParamMeta{{Name: "id", Type: Integer}},
+		QueryParams: []ParamMeta{{Name: "q", Type: String}, {Name: "at", Type: String}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": 7, "q": "everything", "at": "2025-01-01T00:00:00Z"}, skeleton)
+}
+
+func TestWriteSkeleton(t *testing.T) {
+	skeleton := map[string]any{"n1": 42, "n2": 0}
+
+	var out bytes.Buffer
+	assert.NoError(t, writeSkeleton(&out, skeleton, "json"))
+	assert.JSONEq(t, `{"n1": 42, "n2": 0}`, out.String())
+
+	out.Reset()
+	assert.NoError(t, writeSkeleton(&out, skeleton, "YAML"))
+	assert.YAMLEq(t, "n1: 42\nn2: 0\n", out.String())
+
+	assert.Error(t, writeSkeleton(&out, skeleton, "toml"))
+}
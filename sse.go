@@ -0,0 +1,191 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eventStreamMediaType = "text/event-stream"
+	lastEventIDHeader    = "Last-Event-ID"
+)
+
+// Defaults of reconnecting to event streams
+const (
+	sseRetry      = 3 * time.Second // until the server sets it with retry:
+	sseReconnects = 5               // in a row without receiving an event
+	sseMaxLine    = 1 << 20
+)
+
+// An event of a text/event-stream response
+type Event struct {
+	ID    string // the last event ID, it carries over to the events without one
+	Type  string // set by event:, empty for the default message type
+	Data  string // the data: lines joined by newlines
+	Retry int    // the reconnection time in milliseconds from the last retry: line, 0 if none yet
+}
+
+// The data decoded as JSON when it is, the string as is otherwise
+func (e Event) value() any {
+	var value any
+	if err := decodeJSON([]byte(e.Data), &value); err != nil {
+		return e.Data
+	}
+
+	return value
+}
+
+// Reads the events of a stream, keeping the last event ID and retry across reads
+type eventReader struct {
+	last Event
+}
+
+// Calls fn with each event dispatched until the stream ends, returning the error of reading it or of fn
+func (r *eventReader) read(body io.Reader, fn func(Event) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, sseMaxLine)
+
+	var (
+		data    []string
+		hasData bool
+	)
+	event := r.last
+	event.Type, event.Data = "", ""
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if hasData {
+				event.Data = strings.Join(data, "\n")
+				r.last = event
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+
+			event.Type, event.Data, data, hasData = "", "", nil, false
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "":
+			// a comment, eg to keep the connection alive
+		case "data":
+			data, hasData = append(data, value), true
+		case "event":
+			event.Type = value
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				event.ID, r.last.ID = value, value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				event.Retry, r.last.Retry = ms, ms
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Renders the events of resp as they arrive, reconnecting with the Last-Event-ID when the connection drops.
+// It stops when the server ends the stream or on Ctrl-C.
+func (h HandlerData) streamEvents(w io.Writer, req *http.Request, resp *http.Response) error {
+	values, err := renderer{format: h.Output, tty: isTerminal(w), columns: h.Columns, query: h.Query}.values(w)
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+
+	ctx := req.Context()
+	reader := &eventReader{}
+
+	for failures := 0; ; {
+		var writeErr error
+		received := false
+		readErr := reader.read(resp.Body, func(e Event) error {
+			received = true
+			writeErr = values.write(e.value())
+
+			return writeErr
+		})
+		resp.Body.Close()
+
+		switch {
+		case writeErr != nil:
+			return writeErr
+		case ctx.Err() != nil:
+			return streamStopped(ctx)
+		case readErr == nil:
+			return nil
+		case received:
+			failures = 0
+		}
+
+		// Reconnect after the retry time, resuming from the last event
+		for resp = nil; resp == nil; {
+			if failures++; failures > sseReconnects {
+				return readErr
+			}
+
+			delay := sseRetry
+			if reader.last.Retry > 0 {
+				delay = time.Duration(reader.last.Retry) * time.Millisecond
+			}
+
+			select {
+			case <-ctx.Done():
+				return streamStopped(ctx)
+			case <-time.After(delay):
+			}
+
+			next, err := replayRequest(req)
+			if err != nil {
+				return readErr
+			}
+			if reader.last.ID != "" {
+				next.Header.Set(lastEventIDHeader, reader.last.ID)
+			}
+
+			if resp, err = h.Do(next); err != nil {
+				if ctx.Err() != nil {
+					return streamStopped(ctx)
+				}
+				readErr, resp = err, nil
+			}
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNoContent:
+			resp.Body.Close()
+			return nil
+		case resp.StatusCode >= 400:
+			return newHTTPError(resp, h.responses)
+		}
+	}
+}
+
+// Stopping on Ctrl-C is clean, running out of time isn't
+func streamStopped(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return nil
+	}
+
+	return context.Cause(ctx)
+}
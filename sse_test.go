@@ -0,0 +1,183 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sseSpec = `
+openapi: "3.0.0"
+info:
+  title: Events
+  version: "0.1.0"
+paths:
+  "/progress":
+    get:
+      operationId: WatchProgress
+      responses:
+        "200":
+          description: The progress
+          content:
+            text/event-stream:
+              schema:
+                type: string
+        "404":
+          description: Not found
+          content:
+            application/json:
+              schema:
+                type: object
+  "/status":
+    get:
+      operationId: GetStatus
+      responses:
+        "200":
+          description: The status
+          content:
+            application/json:
+              schema:
+                type: object
+`
+
+// Sends two events and drops the connection, then the last one to the reconnection from the second
+func eventsServer(t *testing.T, lastIDs *[]string) *httptest.Server {
+	var mu sync.Mutex
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*lastIDs = append(*lastIDs, r.Header.Get(lastEventIDHeader))
+		mu.Unlock()
+
+		assert.Equal(t, eventStreamMediaType, r.Header.Get("Accept"))
+
+		if r.Header.Get(lastEventIDHeader) == "" {
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+
+			events := "retry: 1\n\nid: 1\ndata: {\"progress\":50}\n\n: still there\nid: 2\nevent: note\ndata: half\ndata: way\n\n"
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nTransfer-Encoding: chunked\r\n\r\n")
+			fmt.Fprintf(buf, "%x\r\n%s\r\n", len(events), events)
+			buf.Flush()
+
+			return
+		}
+
+		w.Header().Set("Content-Type", eventStreamMediaType)
+		fmt.Fprint(w, "id: 3\ndata: {\"progress\":100}\n\n")
+	}))
+}
+
+func TestEventReader(t *testing.T) {
+	var events []Event
+	reader := &eventReader{}
+	collect := func(e Event) error {
+		events = append(events, e)
+		return nil
+	}
+
+	body := "retry: 10\n: comment\nevent: tick\nid: 7\ndata:one\ndata: two\n\ndata: {\"n\":1}\r\n\r\nid\ndata\n\nevent: ignored\n\nid: 8\ndata: cut"
+	assert.NoError(t, reader.read(strings.NewReader(body), collect))
+	assert.Equal(t, []Event{
+		{ID: "7", Type: "tick", Data: "one\ntwo", Retry: 10},
+		{ID: "7", Data: `{"n":1}`, Retry: 10},
+		{Data: "", Retry: 10},
+	}, events)
+
+	// The last event ID carries over to the next read, the unfinished event is dropped
+	assert.Equal(t, "8", reader.last.ID)
+
+	assert.Equal(t, map[string]any{"n": json.Number("1")}, Event{Data: `{"n":1}`}.value())
+	assert.Equal(t, "one\ntwo", Event{Data: "one\ntwo"}.value())
+}
+
+func TestStreamEvents(t *testing.T) {
+	var lastIDs []string
+	server := eventsServer(t, &lastIDs)
+	defer server.Close()
+
+	model, err := LoadV3([]byte(sseSpec))
+	assert.NoError(t, err)
+	assert.Equal(t, eventStreamMediaType, streamingMediaType(model.Model.Paths.PathItems.GetOrZero("/progress").Get))
+	assert.Empty(t, streamingMediaType(model.Model.Paths.PathItems.GetOrZero("/status").Get))
+
+	send := func(output OutputFormat, query string) string {
+		data := HandlerData{Method: "get", Server: server.URL, Path: "/progress", Output: output, Query: query, Streaming: eventStreamMediaType}
+		req, err := data.NewRequest(context.Background(), nil)
+		assert.NoError(t, err)
+
+		var out bytes.Buffer
+		assert.NoError(t, data.Send(&out, req))
+
+		return out.String()
+	}
+
+	assert.Equal(t, `{"progress":50}`+"\n"+`"half\nway"`+"\n"+`{"progress":100}`+"\n", send(JSON, ""))
+	assert.Equal(t, []string{"", "2"}, lastIDs)
+
+	assert.Equal(t, `{"progress":50}`+"\nhalf\nway\n"+`{"progress":100}`+"\n", send(Raw, ""))
+	assert.Equal(t, "50\n100\n", send(JSON, "$.progress"))
+	assert.Equal(t, "VALUE\n50\n100\n", send(Table, "$.progress"))
+}
+
+// Writes to a buffer, calling fn after the first write
+type firstWrite struct {
+	buf bytes.Buffer
+	fn  func()
+}
+
+func (w *firstWrite) Write(p []byte) (int, error) {
+	defer func() {
+		if w.fn != nil {
+			w.fn()
+			w.fn = nil
+		}
+	}()
+
+	return w.buf.Write(p)
+}
+
+func TestStreamEventsStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", eventStreamMediaType)
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			fmt.Fprint(w, "data: late\n\n")
+		}
+	}))
+	defer server.Close()
+
+	data := HandlerData{Method: "get", Server: server.URL, Path: "/logs", Output: Raw}
+
+	// Ctrl-C stops cleanly
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := data.NewRequest(ctx, nil)
+	assert.NoError(t, err)
+
+	out := &firstWrite{fn: cancel}
+	assert.NoError(t, data.Send(out, req))
+	assert.Equal(t, "first\n", out.buf.String())
+
+	// Running out of time doesn't
+	ctx, cancel = context.WithTimeoutCause(context.Background(), 50*time.Millisecond, &TimeoutError{Timeout: 50 * time.Millisecond})
+	defer cancel()
+	req, err = data.NewRequest(ctx, nil)
+	assert.NoError(t, err)
+
+	assert.EqualError(t, data.Send(io.Discard, req), "Timed out after 50ms")
+}
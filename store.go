@@ -0,0 +1,424 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The profile credentials are stored under unless another one is selected
+const defaultProfile = "default"
+
+// A credential kept in a CredentialStore
+type StoredCredential struct {
+	Value        string    `json:"value"`                   // the API key, the access token or user:password
+	RefreshToken string    `json:"refresh_token,omitempty"` // the refresh token of an OAuth2 login
+	Expiry       time.Time `json:"expiry,omitzero"`         // when the value expires, zero if it doesn't
+}
+
+func (c *StoredCredential) valid() bool {
+	if c == nil || c.Value == "" {
+		return false
+	}
+
+	return c.Expiry.IsZero() || time.Now().Add(tokenExpiryLeeway).Before(c.Expiry)
+}
+
+// Stores credentials like tokens and API keys per profile and security scheme
+type CredentialStore interface {
+	// Returns the credential or nil if there's none
+	Get(profile, scheme string) (*StoredCredential, error)
+	Set(profile, scheme string, cred StoredCredential) error
+	Delete(profile, scheme string) error
+}
+
+type storedCredentials map[string]map[string]StoredCredential
+
+func (s storedCredentials) get(profile, scheme string) *StoredCredential {
+	if cred, ok := s[profile][scheme]; ok {
+		return &cred
+	}
+
+	return nil
+}
+
+func (s storedCredentials) set(profile, scheme string, cred StoredCredential) {
+	if s[profile] == nil {
+		s[profile] = make(map[string]StoredCredential)
+	}
+
+	s[profile][scheme] = cred
+}
+
+func (s storedCredentials) delete(profile, scheme string) {
+	delete(s[profile], scheme)
+
+	if len(s[profile]) == 0 {
+		delete(s, profile)
+	}
+}
+
+// Keeps the credentials in memory, mostly useful for tests
+type MemoryStore struct {
+	mu    sync.Mutex
+	creds storedCredentials
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{creds: make(storedCredentials)}
+}
+
+func (m *MemoryStore) Get(profile, scheme string) (*StoredCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.creds.get(profile, scheme), nil
+}
+
+func (m *MemoryStore) Set(profile, scheme string, cred StoredCredential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.creds.set(profile, scheme, cred)
+
+	return nil
+}
+
+func (m *MemoryStore) Delete(profile, scheme string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.creds.delete(profile, scheme)
+
+	return nil
+}
+
+// Keeps the credentials in a JSON file readable only by the user.
+// They're as safe as the user's account, like the credentials files of most CLIs.
+type FileStore struct {
+	mu     sync.Mutex
+	path   string
+	seal   func(plain []byte) []byte
+	unseal func(data []byte) ([]byte, error)
+}
+
+// Creates a store keeping the credentials in the file at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path:   path,
+		seal:   func(plain []byte) []byte { return plain },
+		unseal: func(data []byte) ([]byte, error) { return data, nil },
+	}
+}
+
+// Keeps the credentials in a file encrypted with AES-GCM.
+// It protects them only as well as the key is kept, eg in a keyring, away from the file.
+type EncryptedFileStore struct {
+	FileStore
+}
+
+// Creates a store keeping the credentials in the file at path, encrypted with a 32 byte key
+func NewEncryptedFileStore(path string, key []byte) (*EncryptedFileStore, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("The key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptedFileStore{FileStore{
+		path: path,
+		seal: func(plain []byte) []byte {
+			nonce := make([]byte, aead.NonceSize())
+			rand.Read(nonce)
+
+			return aead.Seal(nonce, nonce, plain, nil)
+		},
+		unseal: func(data []byte) ([]byte, error) {
+			size := aead.NonceSize()
+			if len(data) < size {
+				return nil, errors.New("The credentials file is corrupt")
+			}
+
+			plain, err := aead.Open(nil, data[:size], data[size:], nil)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot decrypt the credentials file: %w", err)
+			}
+
+			return plain, nil
+		},
+	}}, nil
+}
+
+// The store used unless one is provided.
+// A file in the user's config dir, eg ~/.config/calc/credentials.enc, encrypted with the key from the env var
+// named after the root command, eg CALC_CREDENTIALS_KEY, or else with one kept in the OS keyring.
+// A plaintext file is only used when passed explicitly with WithCredentialStore(NewFileStore(path)).
+func DefaultCredentialStore(rootName string) (*EncryptedFileStore, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := defaultStoreKey(rootName)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEncryptedFileStore(filepath.Join(dir, rootName, "credentials.enc"), key)
+}
+
+// The name of the key of the default store, in its env var and in the OS keyring
+const credentialsKeyName = "credentials-key"
+
+// The key of the default store: derived from the env var if set, otherwise the one in the OS keyring,
+// generated and put there on first use
+func defaultStoreKey(rootName string) ([]byte, error) {
+	env := envVarName(rootName, credentialsKeyName)
+	if secret := os.Getenv(env); secret != "" {
+		key := sha256.Sum256([]byte(secret))
+
+		return key[:], nil
+	}
+
+	unavailable := func(err error) error {
+		return fmt.Errorf("Cannot get the key of the credentials from the OS keyring, set %s instead: %w", env, err)
+	}
+
+	encoded, err := keyringGet(rootName, credentialsKeyName)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	if encoded == "" {
+		key := make([]byte, 32)
+		rand.Read(key)
+
+		if err := keyringSet(rootName, credentialsKeyName, base64.StdEncoding.EncodeToString(key)); err != nil {
+			return nil, unavailable(err)
+		}
+
+		return key, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("Invalid key of the credentials in the OS keyring, remove it or set %s instead", env)
+	}
+
+	return key, nil
+}
+
+// Reads a secret from the OS keyring: the login keychain on macOS, the Secret Service via secret-tool elsewhere.
+// It's empty when there's none.
+var keyringGet = func(service, account string) (string, error) {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", errors.New("The OS keyring isn't supported on Windows")
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	}
+
+	out, err := cmd.Output()
+
+	// The tools fail when there's no such secret
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Puts a secret in the OS keyring, replacing the one there if any
+var keyringSet = func(service, account, secret string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", service, "-a", account, "-w", secret)
+	case "windows":
+		return errors.New("The OS keyring isn't supported on Windows")
+	default:
+		cmd = exec.Command("secret-tool", "store", "--label", service+" "+account, "service", service, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func (f *FileStore) Get(profile, scheme string) (*StoredCredential, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	creds, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return creds.get(profile, scheme), nil
+}
+
+func (f *FileStore) Set(profile, scheme string, cred StoredCredential) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	creds, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	creds.set(profile, scheme, cred)
+
+	return f.save(creds)
+}
+
+func (f *FileStore) Delete(profile, scheme string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	creds, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	creds.delete(profile, scheme)
+
+	return f.save(creds)
+}
+
+func (f *FileStore) load() (storedCredentials, error) {
+	creds := make(storedCredentials)
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return creds, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := f.unseal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, fmt.Errorf("Invalid credentials file %s: %w", f.path, err)
+	}
+
+	return creds, nil
+}
+
+// Writes to a temp file readable only by the user first, replacing the old one only when it's complete
+func (f *FileStore) save(creds storedCredentials) error {
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".credentials-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(f.seal(plain)); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// Creates the default store on first use, so that commands not needing it don't touch the disk
+type lazyStore struct {
+	once     sync.Once
+	rootName string
+	store    CredentialStore
+	err      error
+}
+
+func (l *lazyStore) get() (CredentialStore, error) {
+	l.once.Do(func() {
+		l.store, l.err = DefaultCredentialStore(l.rootName)
+	})
+
+	return l.store, l.err
+}
+
+func (l *lazyStore) Get(profile, scheme string) (*StoredCredential, error) {
+	store, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Get(profile, scheme)
+}
+
+func (l *lazyStore) Set(profile, scheme string, cred StoredCredential) error {
+	store, err := l.get()
+	if err != nil {
+		return err
+	}
+
+	return store.Set(profile, scheme, cred)
+}
+
+func (l *lazyStore) Delete(profile, scheme string) error {
+	store, err := l.get()
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(profile, scheme)
+}
@@ -0,0 +1,163 @@
+package climate
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertStoreRoundTrip(t *testing.T, store CredentialStore) {
+	cred := StoredCredential{Value: "token", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour).Round(0)}
+
+	stored, err := store.Get("dev", "sso")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	assert.NoError(t, store.Set("dev", "sso", cred))
+	assert.NoError(t, store.Set("prod", "sso", StoredCredential{Value: "prod-token"}))
+
+	stored, err = store.Get("dev", "sso")
+	assert.NoError(t, err)
+	assert.Equal(t, cred.Value, stored.Value)
+	assert.Equal(t, cred.RefreshToken, stored.RefreshToken)
+	assert.True(t, cred.Expiry.Equal(stored.Expiry))
+
+	assert.NoError(t, store.Delete("dev", "sso"))
+	assert.NoError(t, store.Delete("dev", "missing"))
+
+	stored, err = store.Get("dev", "sso")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	stored, err = store.Get("prod", "sso")
+	assert.NoError(t, err)
+	assert.Equal(t, "prod-token", stored.Value)
+}
+
+func TestMemoryStore(t *testing.T) {
+	assertStoreRoundTrip(t, NewMemoryStore())
+}
+
+func TestEncryptedFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds")
+	key := bytes.Repeat([]byte{7}, 32)
+
+	store, err := NewEncryptedFileStore(path, key)
+	assert.NoError(t, err)
+	assertStoreRoundTrip(t, store)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "prod-token")
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	other, err := NewEncryptedFileStore(path, bytes.Repeat([]byte{8}, 32))
+	assert.NoError(t, err)
+	_, err = other.Get("prod", "sso")
+	assert.ErrorContains(t, err, "Cannot decrypt")
+
+	_, err = NewEncryptedFileStore(path, []byte("short"))
+	assert.Error(t, err)
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calc", "credentials.json")
+
+	store := NewFileStore(path)
+	assertStoreRoundTrip(t, store)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err = store.Get("prod", "sso")
+	assert.ErrorContains(t, err, "Invalid credentials file "+path)
+}
+
+// Keeps the secrets of the OS keyring in memory, or fails like when there's none
+func fakeKeyring(t *testing.T, err error) map[string]string {
+	secrets := make(map[string]string)
+
+	get, set := keyringGet, keyringSet
+	keyringGet = func(service, account string) (string, error) { return secrets[service+"/"+account], err }
+	keyringSet = func(service, account, secret string) error {
+		secrets[service+"/"+account] = secret
+
+		return err
+	}
+	t.Cleanup(func() { keyringGet, keyringSet = get, set })
+
+	return secrets
+}
+
+func TestDefaultCredentialStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	secrets := fakeKeyring(t, nil)
+
+	store, err := DefaultCredentialStore("calc")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set(defaultProfile, "sso", StoredCredential{Value: "token"}))
+	assert.Len(t, secrets["calc/credentials-key"], 44)
+
+	path := filepath.Join(dir, "calc", "credentials.enc")
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "token")
+
+	// The key is taken from the keyring again
+	store, err = DefaultCredentialStore("calc")
+	assert.NoError(t, err)
+	stored, err := store.Get(defaultProfile, "sso")
+	assert.NoError(t, err)
+	assert.Equal(t, "token", stored.Value)
+
+	// Or from the env var, which the file isn't encrypted with
+	t.Setenv("CALC_CREDENTIALS_KEY", "s3cret")
+	store, err = DefaultCredentialStore("calc")
+	assert.NoError(t, err)
+	_, err = store.Get(defaultProfile, "sso")
+	assert.ErrorContains(t, err, "Cannot decrypt")
+
+	secrets["calc/credentials-key"] = "short"
+	t.Setenv("CALC_CREDENTIALS_KEY", "")
+	_, err = DefaultCredentialStore("calc")
+	assert.EqualError(t, err, "Invalid key of the credentials in the OS keyring, remove it or set CALC_CREDENTIALS_KEY instead")
+}
+
+func TestDefaultCredentialStoreWithoutKeyring(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	fakeKeyring(t, errors.New("no keyring"))
+
+	_, err := DefaultCredentialStore("calc")
+	assert.EqualError(t, err, "Cannot get the key of the credentials from the OS keyring, set CALC_CREDENTIALS_KEY instead: no keyring")
+
+	t.Setenv("CALC_CREDENTIALS_KEY", "s3cret")
+	store, err := DefaultCredentialStore("calc")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set(defaultProfile, "sso", StoredCredential{Value: "token"}))
+
+	store, err = DefaultCredentialStore("calc")
+	assert.NoError(t, err)
+	stored, err := store.Get(defaultProfile, "sso")
+	assert.NoError(t, err)
+	assert.Equal(t, "token", stored.Value)
+}
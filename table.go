@@ -0,0 +1,285 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pb33f/jsonpath/pkg/jsonpath"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"go.yaml.in/yaml/v4"
+)
+
+// A column of the table output
+type Column struct {
+	Header string `yaml:"header"` // shown uppercased
+	Path   string `yaml:"path"`   // a JSONPath selecting the value from each item, eg $.metadata.name
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// The JSONPath of a top level field
+func fieldPath(name string) string {
+	if identifier.MatchString(name) {
+		return "$." + name
+	}
+
+	return "$['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(name) + "']"
+}
+
+// The columns of the table output of an operation, the ones set via x-cli-columns if any.
+// Otherwise the scalar properties of the items of the 2xx response, or of the response itself if not a list.
+func makeColumns(op *v3.Operation, exts *extensions) ([]Column, error) {
+	if len(exts.columns) > 0 {
+		for _, column := range exts.columns {
+			if _, err := jsonpath.NewPath(column.Path); err != nil {
+				return nil, fmt.Errorf("Invalid path of column %s in %s: %w", column.Header, op.OperationId, err)
+			}
+		}
+
+		return exts.columns, nil
+	}
+
+	return schemaColumns(successSchema(op)), nil
+}
+
+// The columns of the items of a paginated operation, found at the path of the items in the 2xx response.
+// Only paths of plain fields like $.data.items are followed in the schema.
+func makeItemColumns(op *v3.Operation, exts *extensions) ([]Column, error) {
+	if len(exts.columns) > 0 || exts.pagination.Items == "" {
+		return makeColumns(op, exts)
+	}
+
+	if !fieldsPath.MatchString(exts.pagination.Items) {
+		return nil, nil
+	}
+
+	schema := successSchema(op)
+	for _, name := range strings.Split(exts.pagination.Items, ".")[1:] {
+		if schema == nil || schema.Properties == nil {
+			return nil, nil
+		}
+
+		prop, ok := schema.Properties.Get(name)
+		if !ok {
+			return nil, nil
+		}
+		schema = prop.Schema()
+	}
+
+	return schemaColumns(schema), nil
+}
+
+var fieldsPath = regexp.MustCompile(`^\$(\.[A-Za-z_][A-Za-z0-9_]*)+$`)
+
+// The scalar properties of the items of a list schema, or of the schema itself if not a list
+func schemaColumns(schema *base.Schema) []Column {
+	if schema != nil && schemaType(schema) == "array" {
+		items := schema.Items
+		schema = nil
+		if items != nil && items.IsA() {
+			schema = items.A.Schema()
+		}
+	}
+
+	if schema == nil || schema.Properties == nil {
+		return nil
+	}
+
+	var columns []Column
+	for name, prop := range schema.Properties.FromOldest() {
+		if ps := prop.Schema(); ps != nil && slices.Contains([]string{"object", "array"}, schemaType(ps)) {
+			continue
+		}
+
+		columns = append(columns, Column{Header: name, Path: fieldPath(name)})
+	}
+
+	return columns
+}
+
+// The schema of the first 2xx response with content
+func successSchema(op *v3.Operation) *base.Schema {
+	if op.Responses == nil || op.Responses.Codes == nil {
+		return nil
+	}
+
+	for code, resp := range op.Responses.Codes.FromOldest() {
+		if !strings.HasPrefix(code, "2") || resp == nil {
+			continue
+		}
+
+		if media := preferredMediaType(resp.Content); media != nil && media.Schema != nil {
+			return media.Schema.Schema()
+		}
+	}
+
+	return nil
+}
+
+// Lays out a response as rows: the items of a list, an object as the only row or a scalar as the only cell.
+// All the top level fields are added as columns when wide or when there are no columns.
+func tabulate(value any, columns []Column, wide bool) ([]string, [][]string, error) {
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+
+	columns = tableColumns(items, columns, wide)
+	rows, err := tableRows(items, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var headers []string
+	for _, column := range columns {
+		headers = append(headers, column.Header)
+	}
+
+	return headers, rows, nil
+}
+
+// Adds the top level fields of the items when wide or when there are no columns, a single value column at least
+func tableColumns(items []any, columns []Column, wide bool) []Column {
+	if wide || len(columns) == 0 {
+		columns = slices.Clone(columns)
+		seen := make(map[string]bool)
+		for _, column := range columns {
+			seen[column.Path] = true
+		}
+
+		for _, item := range items {
+			obj, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			for _, key := range slices.Sorted(maps.Keys(obj)) {
+				if path := fieldPath(key); !seen[path] {
+					seen[path] = true
+					columns = append(columns, Column{Header: key, Path: path})
+				}
+			}
+		}
+	}
+
+	if len(columns) == 0 {
+		columns = []Column{{Header: "value", Path: "$"}}
+	}
+
+	return columns
+}
+
+func tableRows(items []any, columns []Column) ([][]string, error) {
+	var paths []*jsonpath.JSONPath
+	for _, column := range columns {
+		path, err := jsonpath.NewPath(column.Path)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid path of column %s: %w", column.Header, err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	var rows [][]string
+	for _, item := range items {
+		node, err := toNode(item)
+		if err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(paths))
+		for i, path := range paths {
+			var cells []string
+			for _, result := range path.Query(node) {
+				cell, err := cellOf(result)
+				if err != nil {
+					return nil, err
+				}
+				cells = append(cells, cell)
+			}
+			row[i] = strings.Join(cells, ",")
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Formats a value as a table cell, nested values as compact JSON
+func cellOf(node *yaml.Node) (string, error) {
+	if node.Kind == yaml.ScalarNode {
+		if node.Tag == "!!null" {
+			return "", nil
+		}
+
+		return node.Value, nil
+	}
+
+	value, err := fromNode(node)
+	if err != nil {
+		return "", err
+	}
+
+	return compactJSON(value), nil
+}
+
+func writeTable(w io.Writer, value any, columns []Column, wide bool) error {
+	if value == nil {
+		return nil
+	}
+
+	headers, rows, err := tabulate(value, columns, wide)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+
+	for i, header := range headers {
+		headers[i] = strings.ToUpper(header)
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, value any, columns []Column) error {
+	if value == nil {
+		return nil
+	}
+
+	headers, rows, err := tabulate(value, columns, false)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
@@ -0,0 +1,148 @@
+package climate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tableSpec = `
+openapi: "3.0.0"
+info:
+  title: Pets
+  version: "0.1.0"
+paths:
+  "/pets":
+    get:
+      operationId: ListPets
+      responses:
+        "200":
+          description: The pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Pet"
+    post:
+      operationId: AddPet
+      responses:
+        "201":
+          description: The pet
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+  "/pets/owners":
+    get:
+      operationId: ListOwners
+      x-cli-columns:
+        - header: owner
+          path: $.owner.name
+        - header: pets
+          path: $.pets[*].name
+      responses:
+        "204":
+          description: No content
+  "/pets/broken":
+    get:
+      operationId: Broken
+      x-cli-columns:
+        - header: oops
+          path: $[
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name:
+          type: string
+        "pet kind":
+          type: string
+        age:
+          type: integer
+        tags:
+          type: array
+          items:
+            type: string
+`
+
+func TestMakeColumns(t *testing.T) {
+	model, err := LoadV3([]byte(tableSpec))
+	assert.NoError(t, err)
+
+	columns := func(path, method string) ([]Column, error) {
+		op := model.Model.Paths.PathItems.GetOrZero(path).GetOperations().GetOrZero(method)
+		exts, err := parseExtensions(op.Extensions)
+		assert.NoError(t, err)
+
+		return makeColumns(op, exts)
+	}
+
+	pet := []Column{
+		{Header: "name", Path: "$.name"},
+		{Header: "pet kind", Path: "$['pet kind']"},
+		{Header: "age", Path: "$.age"},
+	}
+
+	cols, err := columns("/pets", "get")
+	assert.NoError(t, err)
+	assert.Equal(t, pet, cols)
+
+	cols, err = columns("/pets", "post")
+	assert.NoError(t, err)
+	assert.Equal(t, pet, cols)
+
+	cols, err = columns("/pets/owners", "get")
+	assert.NoError(t, err)
+	assert.Equal(t, []Column{{Header: "owner", Path: "$.owner.name"}, {Header: "pets", Path: "$.pets[*].name"}}, cols)
+
+	_, err = columns("/pets/broken", "get")
+	assert.ErrorContains(t, err, "Invalid path of column oops in Broken")
+}
+
+func TestRenderTable(t *testing.T) {
+	pets := []byte(`[
+		{"name": "rex", "pet kind": "dog", "age": 3, "tags": ["good"], "id": 1},
+		{"name": "tom", "pet kind": "cat", "id": 2}
+	]`)
+	columns := []Column{
+		{Header: "name", Path: "$.name"},
+		{Header: "pet kind", Path: "$['pet kind']"},
+		{Header: "age", Path: "$.age"},
+	}
+	rendered := func(resp any, format OutputFormat, columns []Column) string {
+		var out bytes.Buffer
+		assert.NoError(t, renderer{format: format, columns: columns}.render(&out, resp))
+
+		return out.String()
+	}
+
+	assert.Equal(
+		t,
+		"NAME   PET KIND   AGE\n"+
+			"rex    dog        3\n"+
+			"tom    cat        \n",
+		rendered(pets, Table, columns),
+	)
+	assert.Equal(
+		t,
+		"NAME   PET KIND   AGE   ID   TAGS\n"+
+			"rex    dog        3     1    [\"good\"]\n"+
+			"tom    cat              2    \n",
+		rendered(pets, Wide, columns),
+	)
+	assert.Equal(t, "name,pet kind,age\nrex,dog,3\ntom,cat,\n", rendered(pets, CSV, columns))
+
+	owners := []byte(`[{"owner": {"name": "ann"}, "pets": [{"name": "rex"}, {"name": "tom"}]}]`)
+	columns = []Column{{Header: "owner", Path: "$.owner.name"}, {Header: "pets", Path: "$.pets[*].name"}}
+	assert.Equal(t, "OWNER   PETS\nann     rex,tom\n", rendered(owners, Table, columns))
+
+	assert.Equal(t, "VALUE\n1\n2\n", rendered([]byte(`[1, 2]`), Table, nil))
+
+	var out bytes.Buffer
+	data := HandlerData{Output: Table, Columns: []Column{{Header: "name", Path: "$.name"}}}
+	assert.NoError(t, data.Render(&out, pets))
+	assert.Equal(t, "NAME\nrex\ntom\n", out.String())
+}
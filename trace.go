@@ -0,0 +1,206 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	verboseFlag    = "verbose"
+	verboseUsage   = "Log the requests and responses to stderr: -v for their headers and timing, -vv for their bodies too"
+	traceBodyLimit = 64 << 10 // the bytes of a body logged at most
+)
+
+// Logs the requests sent through it and their responses like curl -v, with their secrets masked
+type tracer struct {
+	next http.RoundTripper
+	w    io.Writer
+	h    HandlerData
+}
+
+// A copy of the client logging its requests as set in Verbose
+func (h HandlerData) traced(client *http.Client) *http.Client {
+	t := &tracer{next: client.Transport, w: h.errOut, h: h}
+	if t.next == nil {
+		t.next = http.DefaultTransport
+	}
+	if t.w == nil {
+		t.w = os.Stderr
+	}
+
+	traced := *client
+	traced.Transport = t
+
+	return &traced
+}
+
+func (t *tracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var b strings.Builder
+	shown := t.h.mask(req)
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, shown.URL)
+	for _, line := range headerLines(shown.Header) {
+		b.WriteString("> " + line + "\n")
+	}
+
+	if t.h.Verbose > 1 && req.Body != nil && req.Body != http.NoBody {
+		peeked, body, err := peekBody(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		req.Body = body
+		b.WriteString(t.body(peeked))
+	}
+	// Logged before sending, to see which request hangs
+	io.WriteString(t.w, b.String())
+	b.Reset()
+
+	timing := &timing{start: time.Now()}
+	resp, err := t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), timing.trace())))
+	if err != nil {
+		fmt.Fprintf(t.w, "* Failed after %s: %s\n", time.Since(timing.start).Round(time.Microsecond), err)
+		return nil, err
+	}
+
+	header := resp.Header.Clone()
+	t.h.maskHeader(header)
+	fmt.Fprintf(&b, "< %s %s\n", resp.Proto, resp.Status)
+	for _, line := range headerLines(header) {
+		b.WriteString("< " + line + "\n")
+	}
+	b.WriteString("* " + timing.String() + "\n")
+
+	if t.h.Verbose > 1 && resp.Body != nil && resp.Body != http.NoBody {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if tracedMediaType(mediaType) {
+			peeked, body, err := peekBody(resp.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Body = body
+			b.WriteString(t.body(peeked))
+		} else if resp.ContentLength != 0 {
+			b.WriteString("\n(" + mediaType + " body not shown)\n")
+		}
+	}
+	io.WriteString(t.w, b.String())
+
+	return resp, nil
+}
+
+// A body peeked at as it's logged
+func (t *tracer) body(peeked []byte) string {
+	if len(peeked) == 0 {
+		return ""
+	}
+
+	if len(peeked) > traceBodyLimit {
+		return "\n" + t.h.printableBody(textPrefix(peeked[:traceBodyLimit])) + "\n(truncated)\n"
+	}
+
+	return "\n" + t.h.printableBody(peeked) + "\n"
+}
+
+// The bodies that are logged: JSON and text, streams aren't as they would be read ahead
+func tracedMediaType(mediaType string) bool {
+	if slices.Contains(streamingMediaTypes, mediaType) {
+		return false
+	}
+
+	return mediaType == "" || isJSONMediaType(mediaType) || strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+xml") || mediaType == "application/xml" || mediaType == "application/x-www-form-urlencoded"
+}
+
+// Reads up to one byte past traceBodyLimit of a body, returning them with a body reading all of it again
+func peekBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	peeked, err := io.ReadAll(io.LimitReader(body, traceBodyLimit+1))
+	if err != nil {
+		body.Close()
+		return nil, nil, err
+	}
+
+	return peeked, struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), body), body}, nil
+}
+
+// Text cut off at any byte drops the partial rune at its end, anything else is left as is
+func textPrefix(b []byte) []byte {
+	for end := len(b); end > 0 && end > len(b)-utf8.UTFMax; end-- {
+		if utf8.Valid(b[:end]) {
+			return b[:end]
+		}
+	}
+
+	return b
+}
+
+// The time spent in each phase of a request, from httptrace
+type timing struct {
+	mu                                   sync.Mutex
+	start, dnsStart, connStart, tlsStart time.Time
+	dns, connect, tls, firstByte         time.Duration
+	reused                               bool
+}
+
+func (t *timing) trace() *httptrace.ClientTrace {
+	// The callbacks of dialing run in their own goroutines
+	track := func(fn func()) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		fn()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { track(func() { t.dnsStart = time.Now() }) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { track(func() { t.dns = time.Since(t.dnsStart) }) },
+		ConnectStart:         func(string, string) { track(func() { t.connStart = time.Now() }) },
+		ConnectDone:          func(string, string, error) { track(func() { t.connect = time.Since(t.connStart) }) },
+		TLSHandshakeStart:    func() { track(func() { t.tlsStart = time.Now() }) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { track(func() { t.tls = time.Since(t.tlsStart) }) },
+		GotConn:              func(info httptrace.GotConnInfo) { track(func() { t.reused = info.Reused }) },
+		GotFirstResponseByte: func() { track(func() { t.firstByte = time.Since(t.start) }) },
+	}
+}
+
+// The phases that happened, eg DNS 1.2ms, connect 310µs, TLS 12ms, first byte 40ms
+func (t *timing) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var phases []string
+	if t.reused {
+		phases = append(phases, "reused connection")
+	}
+	for _, phase := range []struct {
+		name string
+		d    time.Duration
+	}{{"DNS", t.dns}, {"connect", t.connect}, {"TLS", t.tls}, {"first byte", t.firstByte}} {
+		if phase.d > 0 {
+			phases = append(phases, phase.name+" "+phase.d.Round(time.Microsecond).String())
+		}
+	}
+
+	return strings.Join(phases, ", ")
+}
@@ -0,0 +1,170 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const traceSpec = `
+openapi: "3.0.0"
+info:
+  title: Accounts
+  version: "0.1.0"
+servers:
+  - url: %s
+paths:
+  "/accounts":
+    post:
+      operationId: CreateAccount
+      security:
+        - keyAuth: []
+      parameters:
+        - name: X-Otp
+          in: header
+          schema:
+            type: string
+            format: password
+        - name: region
+          in: query
+          schema:
+            type: string
+      requestBody:
+        x-cli-name: account
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name:
+                  type: string
+                credentials:
+                  type: array
+                  items:
+                    allOf:
+                      - type: object
+                        properties:
+                          secret:
+                            type: string
+                            format: password
+      responses:
+        "201":
+          description: The account
+components:
+  securitySchemes:
+    keyAuth:
+      type: apiKey
+      in: header
+      name: X-API-Key
+    sessionAuth:
+      type: apiKey
+      in: cookie
+      name: session
+`
+
+// Answers with the account, setting a session cookie
+func accountsServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "k1", r.Header.Get("X-API-Key"))
+
+		w.Header().Set("Content-Type", "application/json")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+}
+
+func TestSecretsOf(t *testing.T) {
+	model, err := LoadV3(fmt.Appendf(nil, traceSpec, "https://example.com"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []secret{
+		{in: "header", name: "X-API-Key"},
+		{in: "cookie", name: "session"},
+		{in: "header", name: "X-Otp"},
+		{in: "body", name: "secret"},
+	}, secretsOf(&model.Model, model.Model.Paths.PathItems.GetOrZero("/accounts").Post))
+}
+
+func TestTrace(t *testing.T) {
+	server := accountsServer(t)
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, traceSpec, server.URL))
+	assert.NoError(t, err)
+
+	var log bytes.Buffer
+	data := HandlerData{
+		Method:      "post",
+		Server:      server.URL,
+		Path:        "/accounts",
+		Credentials: []Credential{{Type: APIKey, In: "header", Name: "X-API-Key", Value: "k1"}},
+		errOut:      &log,
+		secrets:     secretsOf(&model.Model, model.Model.Paths.PathItems.GetOrZero("/accounts").Post),
+	}
+	body := `{"name":"a","credentials":[{"secret":"p1"}]}`
+
+	send := func(verbose int) string {
+		log.Reset()
+		data.Verbose = verbose
+
+		req, err := data.NewRequest(context.Background(), strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("X-Otp", "123456")
+
+		resp, err := data.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		// The response is left to be read
+		received, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, body, string(received))
+
+		return log.String()
+	}
+
+	out := send(1)
+	assert.Contains(t, out, fmt.Sprintf("> POST %s/accounts\n", server.URL))
+	assert.Contains(t, out, "> X-Api-Key: ***\n> X-Otp: ***\n")
+	assert.Contains(t, out, "< HTTP/1.1 201 Created\n")
+	assert.Contains(t, out, "< Set-Cookie: session=***\n")
+	assert.Regexp(t, `\n\* (DNS .+, )?connect .+, first byte .+\n$`, out)
+	assert.NotContains(t, out, `"name":"a"`)
+
+	out = send(2)
+	masked := `{"credentials":[{"secret":"***"}],"name":"a"}`
+	assert.Equal(t, 2, strings.Count(out, "\n"+masked+"\n"))
+	for _, secret := range []string{"k1", "123456", "s1", "p1"} {
+		assert.NotContains(t, out, secret)
+	}
+
+	// Without secrets in the bodies they're logged as is, up to the limit
+	data.secrets, body = nil, strings.Repeat("é", traceBodyLimit)
+	out = send(2)
+	assert.Contains(t, out, "\n"+strings.Repeat("é", traceBodyLimit/2)+"\n(truncated)\n")
+}
+
+func TestTracedMediaType(t *testing.T) {
+	for mediaType, traced := range map[string]bool{
+		"application/json":         true,
+		"application/problem+json": true,
+		"text/plain":               true,
+		"":                         true,
+		"text/event-stream":        false,
+		"application/x-ndjson":     false,
+		"application/octet-stream": false,
+		"image/png":                false,
+	} {
+		assert.Equal(t, traced, tracedMediaType(mediaType), mediaType)
+	}
+}
@@ -9,8 +9,10 @@ package climate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -21,13 +23,17 @@ import (
 
 type HandlerUrfaveCliV3 func(opts *cli.Command, args []string, data HandlerData) error
 
-func addParamsUrfaveCliV3(cmd *cli.Command, op *v3.Operation, handlerData *HandlerData) {
-	var (
-		queryParams  []ParamMeta
-		pathParams   []ParamMeta
-		headerParams []ParamMeta
-		cookieParams []ParamMeta
-	)
+// A handler getting the context of the command, cancelled on Ctrl-C or when the --timeout passes
+type HandlerUrfaveCliV3Ctx func(ctx context.Context, opts *cli.Command, args []string, data HandlerData) error
+
+// Adapts the handler to a HandlerUrfaveCliV3Ctx
+func (h HandlerUrfaveCliV3) Ctx() HandlerUrfaveCliV3Ctx {
+	return func(_ context.Context, opts *cli.Command, args []string, data HandlerData) error {
+		return h(opts, args, data)
+	}
+}
+
+func addParamsUrfaveCliV3(cmd *cli.Command, op *v3.Operation, handlerData *HandlerData, rootName string) error {
 	flags := []cli.Flag{}
 
 	for _, param := range op.Parameters {
@@ -38,57 +44,62 @@ func addParamsUrfaveCliV3(cmd *cli.Command, op *v3.Operation, handlerData *Handl
 		if req := param.Required; req != nil {
 			required = *req
 		}
+		env, err := paramEnvVar(rootName, param)
+		if err != nil {
+			return err
+		}
 
 		switch t {
 		case String:
+			var value string
+			paramDefault(param, &value)
 			flags = append(flags, &cli.StringFlag{
 				Name:     name,
 				Usage:    usage,
 				Required: required,
+				Value:    value,
+				Sources:  cli.EnvVars(env),
 			})
 		case Integer:
+			var value int
+			paramDefault(param, &value)
 			flags = append(flags, &cli.IntFlag{
 				Name:     name,
 				Usage:    usage,
 				Required: required,
+				Value:    value,
+				Sources:  cli.EnvVars(env),
 			})
 		case Number:
+			var value float64
+			paramDefault(param, &value)
 			flags = append(flags, &cli.Float64Flag{
 				Name:     name,
 				Usage:    usage,
 				Required: required,
+				Value:    value,
+				Sources:  cli.EnvVars(env),
 			})
 		case Boolean:
+			var value bool
+			paramDefault(param, &value)
 			flags = append(flags, &cli.BoolFlag{
 				Name:     name,
 				Usage:    usage,
 				Required: required,
+				Value:    value,
+				Sources:  cli.EnvVars(env),
 			})
 		default:
 			// TODO: array, object
 			slog.Warn("TODO: Unhandled param", "name", param.Name, "type", param.Schema.Schema().Type[0])
-			continue
-		}
-
-		// TODO: Extract commom
-		meta := ParamMeta{Name: param.Name, Type: t}
-		switch param.In {
-		case "path":
-			pathParams = append(pathParams, meta)
-		case "query":
-			queryParams = append(queryParams, meta)
-		case "header":
-			headerParams = append(headerParams, meta)
-		case "cookie":
-			cookieParams = append(cookieParams, meta)
 		}
 	}
 
 	cmd.Flags = flags
-	handlerData.QueryParams = queryParams
-	handlerData.PathParams = pathParams
-	handlerData.HeaderParams = headerParams
-	handlerData.CookieParams = cookieParams
+	handlerData.PathParams, handlerData.QueryParams, handlerData.HeaderParams, handlerData.CookieParams = paramMetas(op)
+
+	return nil
 }
 
 func addRequestBodyUrfaveCliV3(cmd *cli.Command, op *v3.Operation, handlerData *HandlerData) error {
@@ -106,6 +117,212 @@ func addRequestBodyUrfaveCliV3(cmd *cli.Command, op *v3.Operation, handlerData *
 	return nil
 }
 
+// The value of the skeleton flag: parsed like a bool flag to be passed alone, meaning the default format then.
+// The like of pflag's NoOptDefVal, which urfave/cli doesn't have.
+type skeletonFormat struct {
+	format string
+}
+
+func (s *skeletonFormat) Set(value string) error {
+	if value == "true" {
+		value = defaultSkeletonFormat
+	}
+	s.format = value
+
+	return nil
+}
+
+func (s *skeletonFormat) Get() any         { return s }
+func (s *skeletonFormat) String() string   { return s.format }
+func (s *skeletonFormat) IsBoolFlag() bool { return true }
+
+func addSkeletonUrfaveCliV3(cmd *cli.Command) {
+	cmd.Flags = append(cmd.Flags, &cli.GenericFlag{
+		Name:  skeletonFlag,
+		Usage: skeletonUsage,
+		Value: &skeletonFormat{},
+		// Flag actions run before the required flags are checked, relax them to only print the skeleton
+		Action: func(_ context.Context, cmd *cli.Command, _ cli.Value) error {
+			for _, flag := range cmd.Flags {
+				switch f := flag.(type) {
+				case *cli.StringFlag:
+					f.Required = false
+				case *cli.IntFlag:
+					f.Required = false
+				case *cli.Float64Flag:
+					f.Required = false
+				case *cli.BoolFlag:
+					f.Required = false
+				}
+			}
+
+			return nil
+		},
+	})
+}
+
+func addPaginationUrfaveCliV3(cmd *cli.Command) {
+	cmd.Flags = append(
+		cmd.Flags,
+		&cli.BoolFlag{Name: allFlag, Usage: allUsage},
+		&cli.IntFlag{Name: maxItemsFlag, Usage: maxItemsUsage},
+	)
+}
+
+func addAuthFlagsUrfaveCliV3(rootCmd *cli.Command, model *v3.Document) {
+	for _, flag := range makeAuthFlags(model) {
+		if hasFlagUrfaveCliV3(rootCmd, flag.name) {
+			continue
+		}
+
+		rootCmd.Flags = append(rootCmd.Flags, &cli.StringFlag{
+			Name:    flag.name,
+			Usage:   flag.usage,
+			Sources: cli.EnvVars(envVarName(rootCmd.Name, flag.name)),
+		})
+	}
+}
+
+// Adds a root flag bound to an env var unless it's already defined
+func addRootFlagUrfaveCliV3(rootCmd *cli.Command, name, value, usage string) {
+	if hasFlagUrfaveCliV3(rootCmd, name) {
+		return
+	}
+
+	rootCmd.Flags = append(rootCmd.Flags, &cli.StringFlag{
+		Name:    name,
+		Usage:   usage,
+		Value:   value,
+		Sources: cli.EnvVars(envVarName(rootCmd.Name, name)),
+	})
+}
+
+// Adds the root --verbose flag counting how often it's passed, eg -v -v, unless it's already defined
+func addVerboseFlagUrfaveCliV3(rootCmd *cli.Command) {
+	if hasFlagUrfaveCliV3(rootCmd, verboseFlag) {
+		return
+	}
+
+	flag := &cli.BoolFlag{Name: verboseFlag, Usage: verboseUsage, Config: cli.BoolConfig{Count: new(int)}}
+	// -v is left to the version flag when there's one
+	if !hasFlagUrfaveCliV3(rootCmd, "v") && rootCmd.Version == "" {
+		flag.Aliases = []string{"v"}
+	}
+	rootCmd.Flags = append(rootCmd.Flags, flag)
+}
+
+// Checks if a flag is defined on the command or one of its parents
+func hasFlagUrfaveCliV3(cmd *cli.Command, name string) bool {
+	for _, c := range cmd.Lineage() {
+		if slices.ContainsFunc(c.Flags, func(f cli.Flag) bool { return slices.Contains(f.Names(), name) }) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Selects the profile before the required flags are checked, filling in the flags not set otherwise from it
+func addProfileUrfaveCliV3(cmd *cli.Command, o *options) {
+	cmd.Before = func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+		p, err := o.selectProfile(cmd.String(profileFlag))
+		if err != nil {
+			return ctx, err
+		}
+
+		for name, value := range p.Flags {
+			if !hasFlagUrfaveCliV3(cmd, name) || cmd.IsSet(name) {
+				continue
+			}
+
+			if err := cmd.Set(name, value); err != nil {
+				return ctx, fmt.Errorf("Invalid value for --%s in profile %s: %w", name, p.name, err)
+			}
+		}
+
+		return withProfile(ctx, p), nil
+	}
+}
+
+// Adds login when a scheme supports it, logout and auth status when there are any schemes
+func addAuthCommandsUrfaveCliV3(rootCmd *cli.Command, model *v3.Document, o *options) {
+	if len(authSchemes(model)) == 0 {
+		return
+	}
+
+	resolver := func(ctx context.Context, cmd *cli.Command) credentialResolver {
+		return newCredentialResolver(model, rootCmd.Name, o, profileFrom(ctx), cmd.String)
+	}
+
+	// Runs like the operations: cancelled on Ctrl-C or when the --timeout passes, failing with their exit codes
+	run := func(run func(ctx context.Context, cmd *cli.Command) error) cli.ActionFunc {
+		return func(ctx context.Context, cmd *cli.Command) error {
+			ctx, cancel, err := commandContext(ctx, cmd.String(timeoutFlag))
+			if err != nil {
+				return err
+			}
+			defer cancel()
+
+			return o.exitCodes.wrap(commandError(ctx, run(ctx, cmd)))
+		}
+	}
+
+	if len(loginSchemes(model)) > 0 {
+		login := &cli.Command{
+			Name:  loginCmd,
+			Usage: loginUsage,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: schemeFlag, Usage: schemeUsage},
+				&cli.BoolFlag{Name: noBrowserFlag, Usage: noBrowserUsage},
+				&cli.BoolFlag{Name: deviceFlag, Usage: deviceUsage},
+			},
+			Action: run(func(ctx context.Context, cmd *cli.Command) error {
+				return resolver(ctx, cmd).login(
+					ctx,
+					cmd.Root().Writer,
+					cmd.String(schemeFlag),
+					!cmd.Bool(noBrowserFlag),
+					cmd.Bool(deviceFlag),
+				)
+			}),
+		}
+		addProfileUrfaveCliV3(login, o)
+
+		rootCmd.Commands = append(rootCmd.Commands, login)
+	}
+
+	logout := &cli.Command{
+		Name:  logoutCmd,
+		Usage: logoutUsage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: schemeFlag, Usage: logoutSchemeUsage},
+		},
+		Action: run(func(ctx context.Context, cmd *cli.Command) error {
+			return resolver(ctx, cmd).logout(cmd.Root().Writer, cmd.String(schemeFlag))
+		}),
+	}
+	addProfileUrfaveCliV3(logout, o)
+
+	status := &cli.Command{
+		Name:  statusCmd,
+		Usage: statusUsage,
+		Action: run(func(ctx context.Context, cmd *cli.Command) error {
+			return resolver(ctx, cmd).status(cmd.Root().Writer)
+		}),
+	}
+	addProfileUrfaveCliV3(status, o)
+
+	rootCmd.Commands = append(
+		rootCmd.Commands,
+		logout,
+		&cli.Command{
+			Name:     authCmd,
+			Usage:    authUsage,
+			Commands: []*cli.Command{status},
+		},
+	)
+}
+
 func interpolatePathUrfaveCliV3(cmd *cli.Command, h *HandlerData) error {
 	// TODO: Extract commom
 	for _, param := range h.PathParams {
@@ -128,9 +345,171 @@ func interpolatePathUrfaveCliV3(cmd *cli.Command, h *HandlerData) error {
 	return nil
 }
 
+// Adds a wait subcommand per waiter, with the flags of the params of the operation it polls
+func addWaitersUrfaveCliV3(rootCmd *cli.Command, model *v3.Document, waiters []*Waiter, o *options) error {
+	if len(waiters) == 0 {
+		return nil
+	}
+
+	waitGroup := &cli.Command{Name: waitCmd, Usage: waitUsage}
+	for _, waiter := range waiters {
+		cmd := &cli.Command{
+			Name:  waiter.Name,
+			Usage: fmt.Sprintf("Wait until %s of %s is one of: %s", waiter.Path, waiter.Operation, strings.Join(waiter.Success, ", ")),
+		}
+
+		hData := HandlerData{
+			Method:    waiter.method,
+			Path:      waiter.path,
+			Columns:   waiter.columns,
+			responses: waiter.target.Responses,
+			client:    o.httpClient,
+		}
+		if err := addParamsUrfaveCliV3(cmd, waiter.target, &hData, rootCmd.Name); err != nil {
+			return err
+		}
+		addProfileUrfaveCliV3(cmd, o)
+
+		cmd.Action = func(ctx context.Context, cmd *cli.Command) error {
+			ctx, cancel, err := commandContext(ctx, cmd.String(timeoutFlag))
+			if err != nil {
+				return err
+			}
+			defer cancel()
+
+			data := hData
+			if _, err := prepareUrfaveCliV3(ctx, cmd, model, waiter.target, o, rootCmd.Name, &data); err != nil {
+				return commandError(ctx, err)
+			}
+			defer data.archive.flush()
+
+			return o.exitCodes.wrap(commandError(ctx, waiter.wait(ctx, cmd.Root().Writer, data)))
+		}
+
+		waitGroup.Commands = append(waitGroup.Commands, cmd)
+	}
+
+	rootCmd.Commands = append(rootCmd.Commands, waitGroup)
+
+	return nil
+}
+
+// Fills in the data of an operation being run from the flags, the profile and the credentials it needs.
+// The resolver is returned to resolve the credentials of other operations.
+func prepareUrfaveCliV3(
+	ctx context.Context,
+	cmd *cli.Command,
+	model *v3.Document,
+	op *v3.Operation,
+	o *options,
+	rootName string,
+	hData *HandlerData,
+) (credentialResolver, error) {
+	var err error
+	hData.param = paramLookupUrfaveCliV3(cmd)
+
+	if err := interpolatePathUrfaveCliV3(cmd, hData); err != nil {
+		return credentialResolver{}, err
+	}
+
+	if hData.Output, err = parseOutputFormat(cmd.String(outputFlag)); err != nil {
+		return credentialResolver{}, err
+	}
+	hData.outputSet = cmd.IsSet(outputFlag)
+
+	// Fail on a bad query before any request is made
+	if hData.Query = cmd.String(queryFlag); hData.Query != "" {
+		if _, err := parseQuery(hData.Query); err != nil {
+			return credentialResolver{}, err
+		}
+	}
+
+	if hData.Validation, err = parseValidationMode(cmd.String(validateFlag)); err != nil {
+		return credentialResolver{}, err
+	}
+
+	if hData.Pagination != nil {
+		hData.AllPages, hData.MaxItems = cmd.Bool(allFlag), int(cmd.Int(maxItemsFlag))
+	}
+
+	if hData.LongRunning != nil {
+		hData.NoWait = cmd.Bool(noWaitFlag)
+	}
+
+	if hData.Binary {
+		hData.OutputFile, hData.Force = cmd.String(outputFileFlag), cmd.Bool(forceFlag)
+	}
+
+	hData.DryRun, hData.Curl, hData.Verbose = cmd.Bool(dryRunFlag), cmd.Bool(curlFlag), cmd.Count(verboseFlag)
+	hData.out, hData.errOut = cmd.Root().Writer, cmd.Root().ErrWriter
+	hData.secrets = secretsOf(model, op)
+
+	if hData.archive, err = openArchive(cmd.String(recordFlag), cmd.String(replayFlag), rootName); err != nil {
+		return credentialResolver{}, err
+	}
+
+	p := profileFrom(ctx)
+	hData.Server = p.serverURL(model)
+
+	resolver := newCredentialResolver(model, rootName, o, p, cmd.String)
+	resolver.replay = hData.archive != nil && hData.archive.replay
+	creds, err := resolver.resolve(ctx, op)
+	if err != nil {
+		return credentialResolver{}, err
+	}
+	hData.Credentials = creds
+
+	return resolver, nil
+}
+
+// Looks up the value of the flag of a param, whether it was set or has a default
+func paramLookupUrfaveCliV3(cmd *cli.Command) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		if !hasFlagUrfaveCliV3(cmd, name) {
+			return "", false
+		}
+
+		value := fmt.Sprint(cmd.Value(name))
+
+		return value, cmd.IsSet(name) || !isZeroFlag(value)
+	}
+}
+
 // Bootstraps a cli.Command with the loaded model and a handler map
-func BootstrapV3UrfaveCliV3(rootCmd *cli.Command, model libopenapi.DocumentModel[v3.Document], handlers map[string]HandlerUrfaveCliV3) error {
+func BootstrapV3UrfaveCliV3(
+	rootCmd *cli.Command,
+	model libopenapi.DocumentModel[v3.Document],
+	handlers map[string]HandlerUrfaveCliV3,
+	opts ...Option,
+) error {
+	ctxHandlers := make(map[string]HandlerUrfaveCliV3Ctx, len(handlers))
+	for id, handler := range handlers {
+		ctxHandlers[id] = handler.Ctx()
+	}
+
+	return BootstrapV3UrfaveCliV3Ctx(rootCmd, model, ctxHandlers, opts...)
+}
+
+// Bootstraps a cli.Command with the loaded model and a map of handlers getting the context of the command
+func BootstrapV3UrfaveCliV3Ctx(
+	rootCmd *cli.Command,
+	model libopenapi.DocumentModel[v3.Document],
+	handlers map[string]HandlerUrfaveCliV3Ctx,
+	opts ...Option,
+) error {
 	cmdGroups := make(map[string][]*cli.Command)
+	var waiters []*Waiter
+	o := makeOptions(rootCmd.Name, opts)
+	addRootFlagUrfaveCliV3(rootCmd, profileFlag, "", profileUsage)
+	addRootFlagUrfaveCliV3(rootCmd, outputFlag, string(JSON), outputUsage)
+	addRootFlagUrfaveCliV3(rootCmd, queryFlag, "", queryUsage)
+	addRootFlagUrfaveCliV3(rootCmd, validateFlag, string(ValidateOff), validateUsage)
+	addRootFlagUrfaveCliV3(rootCmd, timeoutFlag, "", timeoutUsage)
+	addRootFlagUrfaveCliV3(rootCmd, recordFlag, "", recordUsage)
+	addRootFlagUrfaveCliV3(rootCmd, replayFlag, "", replayUsage)
+	addVerboseFlagUrfaveCliV3(rootCmd)
+	addAuthFlagsUrfaveCliV3(rootCmd, &model.Model)
+	addAuthCommandsUrfaveCliV3(rootCmd, &model.Model, o)
 
 	for path, item := range model.Model.Paths.PathItems.FromOldest() {
 		for method, op := range item.GetOperations().FromOldest() {
@@ -144,8 +523,49 @@ func BootstrapV3UrfaveCliV3(rootCmd *cli.Command, model libopenapi.DocumentModel
 				continue
 			}
 
-			hData := HandlerData{Method: method, Path: path}
-			addParamsUrfaveCliV3(&cmd, op, &hData)
+			columns, err := makeColumns(op, exts)
+			if err != nil {
+				return err
+			}
+
+			pagination, err := makePagination(op, exts)
+			if err != nil {
+				return err
+			}
+
+			longRunning, err := makeLongRunning(op, exts)
+			if err != nil {
+				return err
+			}
+
+			retry, err := makeRetry(method, op, exts, o.retry)
+			if err != nil {
+				return err
+			}
+
+			waiter, err := makeWaiter(&model.Model, op, exts)
+			if err != nil {
+				return err
+			}
+			if waiter != nil {
+				waiters = append(waiters, waiter)
+			}
+
+			hData := HandlerData{
+				Method:      method,
+				Path:        path,
+				Columns:     columns,
+				Pagination:  pagination,
+				LongRunning: longRunning,
+				Retry:       retry,
+				Streaming:   streamingMediaType(op),
+				Binary:      isBinaryOperation(op),
+				responses:   op.Responses,
+				client:      o.httpClient,
+			}
+			if err := addParamsUrfaveCliV3(&cmd, op, &hData, rootCmd.Name); err != nil {
+				return err
+			}
 			if err := addRequestBodyUrfaveCliV3(&cmd, op, &hData); err != nil {
 				return err
 			}
@@ -156,18 +576,77 @@ func BootstrapV3UrfaveCliV3(rootCmd *cli.Command, model libopenapi.DocumentModel
 				continue
 			}
 
+			// Only fails when asked for, eg for a body without a schema
+			skeleton, skeletonErr := makeSkeleton(op, &hData)
+			addSkeletonUrfaveCliV3(&cmd)
+			if pagination != nil {
+				addPaginationUrfaveCliV3(&cmd)
+			}
+			if longRunning != nil {
+				cmd.Flags = append(cmd.Flags, &cli.BoolFlag{Name: noWaitFlag, Usage: noWaitUsage})
+			}
+			if hData.Binary {
+				cmd.Flags = append(
+					cmd.Flags,
+					&cli.StringFlag{Name: outputFileFlag, Usage: outputFileUsage},
+					&cli.BoolFlag{Name: forceFlag, Usage: forceUsage},
+				)
+			}
+			cmd.Flags = append(
+				cmd.Flags,
+				&cli.BoolFlag{Name: dryRunFlag, Usage: dryRunUsage},
+				&cli.BoolFlag{Name: curlFlag, Usage: curlUsage},
+			)
+			if waiter != nil {
+				cmd.Flags = append(cmd.Flags, &cli.BoolFlag{Name: waitFlag, Usage: fmt.Sprintf(waitFlagUsage, waiter.Name)})
+			}
+			addProfileUrfaveCliV3(&cmd, o)
+
 			cmd.Hidden = exts.hidden
 			cmd.Aliases = exts.aliases
 			cmd.Usage = op.Description
 			if op.Summary != "" {
 				cmd.Usage = op.Summary
 			}
-			cmd.Action = func(_ context.Context, cmd *cli.Command) error {
-				if err := interpolatePathUrfaveCliV3(cmd, &hData); err != nil {
+			cmd.Action = func(ctx context.Context, cmd *cli.Command) error {
+				if format := cmd.Generic(skeletonFlag).String(); cmd.IsSet(skeletonFlag) && format != "" {
+					if skeletonErr != nil {
+						return skeletonErr
+					}
+
+					return writeSkeleton(cmd.Root().Writer, skeleton, format)
+				}
+
+				ctx, cancel, err := commandContext(ctx, cmd.String(timeoutFlag))
+				if err != nil {
 					return err
 				}
+				defer cancel()
+
+				data := hData
+				resolver, err := prepareUrfaveCliV3(ctx, cmd, &model.Model, op, o, rootCmd.Name, &data)
+				if err != nil {
+					return commandError(ctx, err)
+				}
+				defer data.archive.flush()
 
-				return handler(cmd, cmd.Args().Slice(), hData)
+				if waiter != nil && cmd.Bool(waitFlag) {
+					data.response = &operationResponse{}
+				}
+
+				err = handler(ctx, cmd, cmd.Args().Slice(), data)
+				if errors.Is(err, ErrDryRun) {
+					return nil
+				}
+				if err != nil {
+					return o.exitCodes.wrap(commandError(ctx, err))
+				}
+
+				if waiter != nil && cmd.Bool(waitFlag) {
+					return o.exitCodes.wrap(commandError(ctx, waiter.waitAfter(ctx, cmd.Root().Writer, data, resolver)))
+				}
+
+				return nil
 			}
 
 			cmd.Name = op.OperationId // default
@@ -200,5 +679,5 @@ func BootstrapV3UrfaveCliV3(rootCmd *cli.Command, model libopenapi.DocumentModel
 		rootCmd.Commands = append(rootCmd.Commands, &groupedCmd)
 	}
 
-	return nil
+	return addWaitersUrfaveCliV3(rootCmd, &model.Model, waiters, o)
 }
@@ -1,8 +1,18 @@
 package climate
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli/v3"
@@ -184,3 +194,460 @@ func TestBootstrapV3UrfaveCliV3(t *testing.T) {
 		},
 	))
 }
+
+func TestGenerateSkeletonUrfaveCliV3(t *testing.T) {
+	model, err := LoadFileV3("api.yaml")
+	assert.NoError(t, err)
+
+	handler := func(opts *cli.Command, args []string, data HandlerData) error {
+		t.Fatal("handler must not be called when generating a skeleton")
+
+		return nil
+	}
+	var out bytes.Buffer
+	rootCmd := &cli.Command{Name: "calc", Writer: &out}
+
+	err = BootstrapV3UrfaveCliV3(rootCmd, *model, map[string]HandlerUrfaveCliV3{"AddPost": handler})
+	assert.NoError(t, err)
+
+	assert.NoError(t, rootCmd.Run(
+		context.Background(),
+		[]string{"calc", "ops", "add-post", "--generate-skeleton=yaml"},
+	))
+	assert.Equal(t, "n1: 42\nn2: 0\n", out.String())
+
+	// JSON unless given
+	out.Reset()
+	assert.NoError(t, rootCmd.Run(context.Background(), []string{"calc", "ops", "add-post", "--generate-skeleton"}))
+	assert.Equal(t, "{\n  \"n1\": 42,\n  \"n2\": 0\n}\n", out.String())
+}
+
+func TestCredentialsUrfaveCliV3(t *testing.T) {
+	model, err := LoadV3([]byte(securitySpec))
+	assert.NoError(t, err)
+
+	var creds []Credential
+	handler := func(opts *cli.Command, args []string, data HandlerData) error {
+		creds = data.Credentials
+
+		return nil
+	}
+	rootCmd := &cli.Command{Name: "secure"}
+	handlers := map[string]HandlerUrfaveCliV3{
+		"Inherited": handler,
+		"Combined":  handler,
+	}
+
+	err = BootstrapV3UrfaveCliV3(rootCmd, *model, handlers, WithCredentialStore(NewMemoryStore()))
+	assert.NoError(t, err)
+
+	t.Setenv("SECURE_API_KEY", "key")
+	assert.NoError(t, rootCmd.Run(context.Background(), []string{"secure", "Combined", "--token", "tok"}))
+	assert.Equal(
+		t,
+		[]Credential{
+			{Scheme: "keyAuth", Type: APIKey, In: "header", Name: "X-API-Key", Value: "key"},
+			{Scheme: "bearerAuth", Type: Bearer, Value: "tok"},
+		},
+		creds,
+	)
+}
+
+func TestLoginUrfaveCliV3(t *testing.T) {
+	fakeBrowser(t)
+
+	server := authServer(t, false)
+	model, err := LoadV3([]byte(fmt.Sprintf(loginSpec, server.URL)))
+	assert.NoError(t, err)
+
+	var creds []Credential
+	handler := func(opts *cli.Command, args []string, data HandlerData) error {
+		creds = data.Credentials
+
+		return nil
+	}
+	var out bytes.Buffer
+	rootCmd := &cli.Command{Name: "me", Writer: &out, ErrWriter: io.Discard, ExitErrHandler: func(context.Context, *cli.Command, error) {}}
+
+	err = BootstrapV3UrfaveCliV3(
+		rootCmd,
+		*model,
+		map[string]HandlerUrfaveCliV3{"GetMe": handler},
+		WithCredentialStore(NewMemoryStore()),
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, rootCmd.Run(context.Background(), []string{"me", "login", "--client-id", "cli"}))
+	assert.Contains(t, out.String(), "Logged in to user")
+
+	assert.NoError(t, rootCmd.Run(context.Background(), []string{"me", "GetMe"}))
+	assert.Equal(t, []Credential{{Scheme: "user", Type: Bearer, Value: "user-token"}}, creds)
+
+	// Waiting for the browser gives up like the operations
+	err = rootCmd.Run(context.Background(), []string{"me", "login", "--client-id", "cli", "--no-browser", "--timeout", "20ms"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, DefaultExitCodes.Timeout, ExitCode(err))
+}
+
+func TestAuthCommandsUrfaveCliV3(t *testing.T) {
+	model, err := LoadV3([]byte(securitySpec))
+	assert.NoError(t, err)
+
+	store := NewMemoryStore()
+	assert.NoError(t, store.Set(defaultProfile, "bearerAuth", StoredCredential{Value: "tok"}))
+
+	handler := func(opts *cli.Command, args []string, data HandlerData) error { return nil }
+	var out bytes.Buffer
+	rootCmd := &cli.Command{Name: "secure", Writer: &out}
+
+	err = BootstrapV3UrfaveCliV3(
+		rootCmd,
+		*model,
+		map[string]HandlerUrfaveCliV3{"Inherited": handler},
+		WithCredentialStore(store),
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, rootCmd.Run(context.Background(), []string{"secure", "auth", "status"}))
+	assert.Contains(t, out.String(), "bearerAuth (http): logged in")
+
+	assert.NoError(t, rootCmd.Run(context.Background(), []string{"secure", "logout"}))
+	assert.Contains(t, out.String(), "Logged out of bearerAuth")
+
+	stored, err := store.Get(defaultProfile, "bearerAuth")
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestProfilesUrfaveCliV3(t *testing.T) {
+	model, err := LoadV3([]byte(profileSpec))
+	assert.NoError(t, err)
+
+	config := WithConfigFile(writeConfig(t, profileConfig))
+
+	var (
+		data   HandlerData
+		values []any
+	)
+	run := func(args ...string) error {
+		handler := func(opts *cli.Command, args []string, d HandlerData) error {
+			data, values = d, []any{opts.String("owner"), opts.Int("limit"), opts.String("sort")}
+
+			return nil
+		}
+		rootCmd := &cli.Command{Name: "profiles", Writer: io.Discard, ErrWriter: io.Discard}
+
+		err := BootstrapV3UrfaveCliV3(rootCmd, *model, map[string]HandlerUrfaveCliV3{"ListItems": handler}, config)
+		assert.NoError(t, err)
+
+		return rootCmd.Run(context.Background(), append([]string{"profiles"}, args...))
+	}
+
+	assert.NoError(t, run("ListItems"))
+	assert.Equal(t, "https://dev.example.com/v1", data.Server)
+	assert.Equal(t, []any{"me", 50, "name"}, values)
+	assert.Equal(t, JSON, data.Output)
+	assert.False(t, data.outputSet)
+
+	assert.NoError(t, run("ListItems", "--output", "table"))
+	assert.Equal(t, Table, data.Output)
+	assert.True(t, data.outputSet)
+	assert.ErrorContains(t, run("ListItems", "--output", "xml"), "Unknown output format xml")
+
+	assert.NoError(t, run("ListItems", "--query", "$[*].name"))
+	assert.Equal(t, "$[*].name", data.Query)
+	assert.ErrorContains(t, run("ListItems", "--query", "$["), "Invalid query")
+
+	assert.NoError(t, run("ListItems", "--validate-response", "strict"))
+	assert.Equal(t, ValidateStrict, data.Validation)
+	assert.ErrorContains(t, run("ListItems", "--validate-response", "loud"), "Unknown validation mode loud")
+	assert.Equal(t, []Credential{{Scheme: "bearerAuth", Type: Bearer, Value: "dev-token"}}, data.Credentials)
+
+	t.Setenv("PROFILES_TOKEN", "env-token")
+	assert.NoError(t, run("ListItems", "--limit", "5"))
+	assert.Equal(t, []any{"me", 5, "name"}, values)
+	assert.Equal(t, "env-token", data.Credentials[0].Value)
+
+	t.Setenv("PROFILES_LIMIT", "20")
+	t.Setenv("ITEMS_SORT", "date")
+	assert.NoError(t, run("ListItems"))
+	assert.Equal(t, []any{"me", 20, "date"}, values)
+
+	assert.NoError(t, run("ListItems", "--sort", "size"))
+	assert.Equal(t, []any{"me", 20, "size"}, values)
+
+	t.Setenv("PROFILES_LIMIT", "many")
+	assert.ErrorContains(t, run("ListItems"), "many")
+	os.Unsetenv("PROFILES_LIMIT")
+	os.Unsetenv("ITEMS_SORT")
+
+	assert.ErrorContains(t, run("--profile", "local", "ListItems"), `"owner" not set`)
+
+	t.Setenv("PROFILES_PROFILE", "local")
+	assert.NoError(t, run("ListItems", "--owner", "you"))
+	assert.Equal(t, "http://localhost:9000", data.Server)
+	assert.Equal(t, []any{"you", 10, "name"}, values)
+
+	assert.ErrorContains(t, run("--profile", "prod", "ListItems"), "Unknown profile prod")
+}
+
+func TestExitCodesUrfaveCliV3(t *testing.T) {
+	model, err := LoadV3([]byte(profileSpec))
+	assert.NoError(t, err)
+
+	run := func(handlerErr error, opts ...Option) int {
+		handler := func(opts *cli.Command, args []string, data HandlerData) error {
+			return handlerErr
+		}
+
+		code := 0
+		rootCmd := &cli.Command{
+			Name:      "profiles",
+			Writer:    io.Discard,
+			ErrWriter: io.Discard,
+			ExitErrHandler: func(ctx context.Context, cmd *cli.Command, err error) {
+				if exitErr, ok := err.(cli.ExitCoder); ok {
+					code = exitErr.ExitCode()
+				}
+			},
+		}
+
+		opts = append(opts, WithConfigFile(writeConfig(t, profileConfig)))
+		err := BootstrapV3UrfaveCliV3(rootCmd, *model, map[string]HandlerUrfaveCliV3{"ListItems": handler}, opts...)
+		assert.NoError(t, err)
+
+		err = rootCmd.Run(context.Background(), []string{"profiles", "ListItems"})
+		assert.Equal(t, handlerErr, errors.Unwrap(err))
+
+		return code
+	}
+
+	assert.Equal(t, 2, run(&HTTPError{Status: 404}))
+	assert.Equal(t, 3, run(&HTTPError{Status: 500}))
+	assert.Equal(t, 10, run(&HTTPError{Status: 404}, WithExitCodes(ExitCodes{Statuses: map[int]int{404: 10}})))
+}
+
+func TestPaginationUrfaveCliV3(t *testing.T) {
+	model, err := LoadV3([]byte(paginateSpec))
+	assert.NoError(t, err)
+
+	var data HandlerData
+	handler := func(opts *cli.Command, args []string, d HandlerData) error {
+		data = d
+		return nil
+	}
+	handlers := map[string]HandlerUrfaveCliV3{"ListByCursor": handler, "ListByOffset": handler}
+
+	rootCmd := &cli.Command{Name: "pages", Writer: io.Discard, ErrWriter: io.Discard}
+	assert.ErrorContains(t, BootstrapV3UrfaveCliV3(rootCmd, *model, handlers), "Invalid x-cli-pagination in Broken")
+
+	model.Model.Paths.PathItems.Delete("/broken")
+	run := func(args ...string) {
+		rootCmd := &cli.Command{Name: "pages", Writer: io.Discard, ErrWriter: io.Discard}
+		assert.NoError(t, BootstrapV3UrfaveCliV3(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+		assert.NoError(t, rootCmd.Run(context.Background(), append([]string{"pages"}, args...)))
+	}
+
+	run("ListByCursor", "--max-items", "5")
+	assert.Equal(t, CursorPagination, data.Pagination.Strategy)
+	assert.False(t, data.AllPages)
+	assert.Equal(t, 5, data.MaxItems)
+
+	run("ListByOffset", "--all")
+	assert.Equal(t, OffsetPagination, data.Pagination.Strategy)
+	assert.True(t, data.AllPages)
+}
+
+func TestWaitersUrfaveCliV3(t *testing.T) {
+	server := clustersServer(t)
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, waiterSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerUrfaveCliV3{
+		"CreateCluster": func(cmd *cli.Command, args []string, data HandlerData) error {
+			req, err := data.NewRequest(context.Background(), nil)
+			if err != nil {
+				return err
+			}
+
+			return data.Send(cmd.Root().Writer, req)
+		},
+	}
+	handlers["GenerateCluster"] = handlers["CreateCluster"]
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		rootCmd := &cli.Command{Name: "clusters", Writer: &out, ErrWriter: io.Discard, ExitErrHandler: func(context.Context, *cli.Command, error) {}}
+		assert.NoError(t, BootstrapV3UrfaveCliV3(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+		err := rootCmd.Run(context.Background(), append([]string{"clusters"}, args...))
+
+		return out.String(), err
+	}
+
+	out, err := run("CreateCluster", "--name", "ok", "--wait")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"ok","status":"PROVISIONING"}`+"\n"+`{"name":"ok","status":"READY"}`+"\n", out)
+
+	// The cluster to wait for is the one in the response
+	out, err = run("GenerateCluster", "--wait")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"ok","status":"PROVISIONING"}`+"\n"+`{"name":"ok","status":"READY"}`+"\n", out)
+
+	out, err = run("wait", "cluster-ready", "--name", "ok", "--output", "table")
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME   STATUS\nok     READY\n", out)
+
+	_, err = run("wait", "cluster-ready", "--name", "bad")
+	assert.EqualError(t, err, "Waiting for cluster-ready failed, the state is FAILED")
+	assert.Equal(t, DefaultExitCodes.WaitFailure, ExitCode(err))
+}
+
+func TestLongRunningUrfaveCliV3(t *testing.T) {
+	model, err := LoadV3([]byte(lroSpec))
+	assert.NoError(t, err)
+	model.Model.Paths.PathItems.Delete("/broken")
+
+	var data HandlerData
+	handler := func(cmd *cli.Command, args []string, d HandlerData) error {
+		data = d
+		return nil
+	}
+	handlers := map[string]HandlerUrfaveCliV3{"StartJob": handler, "ListJobs": handler}
+	run := func(args ...string) error {
+		rootCmd := &cli.Command{Name: "jobs", Writer: io.Discard, ErrWriter: io.Discard}
+		assert.NoError(t, BootstrapV3UrfaveCliV3(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+
+		return rootCmd.Run(context.Background(), append([]string{"jobs"}, args...))
+	}
+
+	assert.NoError(t, run("StartJob", "--no-wait"))
+	assert.NotNil(t, data.LongRunning)
+	assert.True(t, data.NoWait)
+
+	assert.NoError(t, run("ListJobs"))
+	assert.Nil(t, data.LongRunning)
+	assert.ErrorContains(t, run("ListJobs", "--no-wait"), "flag provided but not defined: -no-wait")
+}
+
+func TestContextUrfaveCliV3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, waiterSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerUrfaveCliV3Ctx{
+		"CreateCluster": func(ctx context.Context, cmd *cli.Command, args []string, data HandlerData) error {
+			req, err := data.NewRequest(ctx, nil)
+			if err != nil {
+				return err
+			}
+
+			return data.Send(io.Discard, req)
+		},
+	}
+	rootCmd := &cli.Command{Name: "clusters", Writer: io.Discard, ErrWriter: io.Discard, ExitErrHandler: func(context.Context, *cli.Command, error) {}}
+	assert.NoError(t, BootstrapV3UrfaveCliV3Ctx(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+
+	err = rootCmd.Run(context.Background(), []string{"clusters", "CreateCluster", "--name", "ok", "--timeout", "20ms"})
+	assert.ErrorContains(t, err, "Timed out after 20ms")
+	assert.Equal(t, DefaultExitCodes.Timeout, ExitCode(err))
+}
+
+func TestDryRunUrfaveCliV3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The request was sent")
+	}))
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, dryRunSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerUrfaveCliV3Ctx{
+		"PutItem": func(ctx context.Context, cmd *cli.Command, args []string, data HandlerData) error {
+			req, err := data.NewRequest(ctx, strings.NewReader(`{"name":"a"}`))
+			if err != nil {
+				return err
+			}
+
+			return data.Send(cmd.Root().Writer, req)
+		},
+	}
+	var out bytes.Buffer
+	rootCmd := &cli.Command{Name: "items", Writer: &out, ErrWriter: io.Discard, ExitErrHandler: func(context.Context, *cli.Command, error) {}}
+	assert.NoError(t, BootstrapV3UrfaveCliV3Ctx(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+
+	assert.NoError(t, rootCmd.Run(context.Background(), []string{"items", "PutItem", "--id", "i1", "--token", "t1", "--curl"}))
+	assert.Equal(t, fmt.Sprintf("curl -X PUT '%s/items/i1' \\\n  -H 'Authorization: Bearer ***' \\\n  --data-binary '{\"name\":\"a\"}'\n", server.URL), out.String())
+}
+
+func TestVerboseUrfaveCliV3(t *testing.T) {
+	server := accountsServer(t)
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, traceSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerUrfaveCliV3Ctx{
+		"CreateAccount": func(ctx context.Context, cmd *cli.Command, args []string, data HandlerData) error {
+			req, err := data.NewRequest(ctx, strings.NewReader(cmd.String("account")))
+			if err != nil {
+				return err
+			}
+
+			return data.Send(io.Discard, req)
+		},
+	}
+	var log bytes.Buffer
+	rootCmd := &cli.Command{Name: "accounts", Writer: io.Discard, ErrWriter: &log, ExitErrHandler: func(context.Context, *cli.Command, error) {}}
+	assert.NoError(t, BootstrapV3UrfaveCliV3Ctx(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+
+	assert.NoError(t, rootCmd.Run(context.Background(), []string{"accounts", "-v", "CreateAccount", "--api-key", "k1", "--account", `{"name":"a"}`}))
+	assert.Contains(t, log.String(), "> X-Api-Key: ***\n")
+	assert.Contains(t, log.String(), "< HTTP/1.1 201 Created\n")
+	assert.NotContains(t, log.String(), `{"name":"a"}`)
+}
+
+func TestReplayUrfaveCliV3(t *testing.T) {
+	server := accountsServer(t)
+	model, err := LoadV3(fmt.Appendf(nil, traceSpec, server.URL))
+	assert.NoError(t, err)
+
+	handlers := map[string]HandlerUrfaveCliV3Ctx{
+		"CreateAccount": func(ctx context.Context, cmd *cli.Command, args []string, data HandlerData) error {
+			req, err := data.NewRequest(ctx, strings.NewReader(cmd.String("account")))
+			if err != nil {
+				return err
+			}
+
+			return data.Send(cmd.Root().Writer, req)
+		},
+	}
+	run := func() (string, error) {
+		var out bytes.Buffer
+		rootCmd := &cli.Command{Name: "accounts", Writer: &out, ErrWriter: io.Discard, ExitErrHandler: func(context.Context, *cli.Command, error) {}}
+		assert.NoError(t, BootstrapV3UrfaveCliV3Ctx(rootCmd, *model, handlers, WithConfigFile(writeConfig(t, ""))))
+		err := rootCmd.Run(context.Background(), []string{"accounts", "CreateAccount", "--api-key", "k1", "--account", `{"name":"a"}`})
+
+		return out.String(), err
+	}
+
+	path := filepath.Join(t.TempDir(), "session.har")
+	t.Setenv("ACCOUNTS_RECORD", path)
+	recorded, err := run()
+	assert.NoError(t, err)
+	assert.Contains(t, recorded, `"name"`)
+	server.Close()
+
+	t.Setenv("ACCOUNTS_RECORD", "")
+	t.Setenv("ACCOUNTS_REPLAY", path)
+	replayed, err := run()
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+}
@@ -0,0 +1,392 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+// How responses are checked against the spec
+type ValidationMode string
+
+const (
+	ValidateOff    ValidationMode = "off"
+	ValidateWarn   ValidationMode = "warn"   // logs the mismatches
+	ValidateStrict ValidationMode = "strict" // fails on mismatches
+)
+
+var validationModes = []ValidationMode{ValidateOff, ValidateWarn, ValidateStrict}
+
+const (
+	validateFlag  = "validate-response"
+	validateUsage = "Check responses against the spec: off, warn or strict"
+)
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// A difference between a response and the spec
+type Mismatch struct {
+	Pointer string // the JSON pointer to the offending value in the body, empty for the status, content type and root
+	Message string
+}
+
+func (m Mismatch) String() string {
+	if m.Pointer == "" {
+		return m.Message
+	}
+
+	return m.Pointer + ": " + m.Message
+}
+
+// The error of a response not matching the spec when validating strictly
+type ResponseValidationError struct {
+	Status     int
+	Mismatches []Mismatch
+}
+
+func (e *ResponseValidationError) Error() string {
+	var lines []string
+	for _, m := range e.Mismatches {
+		lines = append(lines, m.String())
+	}
+
+	return fmt.Sprintf("Response with status %d does not match the spec:\n  %s", e.Status, strings.Join(lines, "\n  "))
+}
+
+func parseValidationMode(mode string) (ValidationMode, error) {
+	if m := ValidationMode(strings.ToLower(mode)); slices.Contains(validationModes, m) {
+		return m, nil
+	}
+
+	var names []string
+	for _, m := range validationModes {
+		names = append(names, string(m))
+	}
+
+	return "", fmt.Errorf("Unknown validation mode %s, choose one of: %s", mode, strings.Join(names, ", "))
+}
+
+// Checks the status, content type and body of a response against the responses of the operation in the spec.
+// Mismatches are logged when warning and returned as a *ResponseValidationError when strict, for the successful
+// responses only: the others are logged too.
+// The body is read and put back, the response can be rendered afterwards.
+func (h HandlerData) ValidateResponse(resp *http.Response) error {
+	if h.Validation == "" || h.Validation == ValidateOff || h.responses == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	mismatches := validateResponse(h.responses, resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	// Failures are returned as the HTTP errors they are, with the exit codes of their status
+	if h.Validation == ValidateStrict && resp.StatusCode < 300 {
+		return &ResponseValidationError{Status: resp.StatusCode, Mismatches: mismatches}
+	}
+
+	for _, m := range mismatches {
+		slog.Warn("Response does not match the spec", "status", resp.StatusCode, "pointer", m.Pointer, "error", m.Message)
+	}
+
+	return nil
+}
+
+func validateResponse(responses *v3.Responses, status int, contentType string, body []byte) []Mismatch {
+	spec, codes := matchResponse(responses, status)
+	if spec == nil {
+		return []Mismatch{{Message: fmt.Sprintf("Unexpected status %d, expected one of: %s", status, strings.Join(codes, ", "))}}
+	}
+
+	empty := len(bytes.TrimSpace(body)) == 0
+	if spec.Content == nil || spec.Content.Len() == 0 {
+		if !empty {
+			return []Mismatch{{Message: fmt.Sprintf("Unexpected body for status %d, the spec declares none", status)}}
+		}
+
+		return nil
+	}
+
+	if empty {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, declared := matchMediaType(spec, mediaType)
+	if media == nil {
+		return []Mismatch{{
+			Message: fmt.Sprintf("Unexpected content type %q, expected one of: %s", contentType, strings.Join(declared, ", ")),
+		}}
+	}
+
+	if media.Schema == nil || !isJSONMediaType(mediaType) {
+		return nil
+	}
+
+	var value any
+	if err := decodeJSON(body, &value); err != nil {
+		return []Mismatch{{Message: fmt.Sprintf("Invalid JSON body: %s", err)}}
+	}
+
+	return validateValue(media.Schema, value, "")
+}
+
+// The response for the exact code, then the range like 4XX and finally the default
+func matchResponse(responses *v3.Responses, status int) (*v3.Response, []string) {
+	code := strconv.Itoa(status)
+
+	var codes []string
+	if responses.Codes != nil {
+		codes = slices.Collect(responses.Codes.KeysFromOldest())
+	}
+
+	for _, candidate := range []string{code, code[:1] + "XX"} {
+		for _, c := range codes {
+			if strings.EqualFold(c, candidate) {
+				resp, _ := responses.Codes.Get(c)
+				return resp, codes
+			}
+		}
+	}
+
+	if responses.Default != nil {
+		return responses.Default, codes
+	}
+
+	return nil, codes
+}
+
+// The media type matching exactly or by a wildcard like application/*
+func matchMediaType(resp *v3.Response, mediaType string) (*v3.MediaType, []string) {
+	var declared []string
+	for key, media := range resp.Content.FromOldest() {
+		declared = append(declared, key)
+
+		m, _, err := mime.ParseMediaType(key)
+		if err != nil {
+			continue
+		}
+
+		if m == mediaType || m == "*/*" || (strings.HasSuffix(m, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(m, "*"))) {
+			return media, declared
+		}
+	}
+
+	return nil, declared
+}
+
+// Validates a decoded JSON value against a schema, the mismatches point into the value
+func validateValue(proxy *base.SchemaProxy, value any, pointer string) []Mismatch {
+	if proxy == nil {
+		return nil
+	}
+
+	schema := proxy.Schema()
+	if schema == nil {
+		return nil
+	}
+
+	var mismatches []Mismatch
+	fail := func(format string, args ...any) {
+		mismatches = append(mismatches, Mismatch{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil && ((schema.Nullable != nil && *schema.Nullable) || slices.Contains(schema.Type, "null")) {
+		return nil
+	}
+
+	for _, sub := range schema.AllOf {
+		mismatches = append(mismatches, validateValue(sub, value, pointer)...)
+	}
+
+	if len(schema.AnyOf) > 0 && countMatches(schema.AnyOf, value) == 0 {
+		fail("does not match any of the schemas in anyOf")
+	}
+
+	if len(schema.OneOf) > 0 {
+		if n := countMatches(schema.OneOf, value); n != 1 {
+			fail("matches %d of the schemas in oneOf, expected exactly one", n)
+		}
+	}
+
+	if len(schema.Type) > 0 {
+		actual := jsonTypeOf(value)
+		if !slices.ContainsFunc(schema.Type, func(t string) bool { return t == actual || (t == "number" && actual == "integer") }) {
+			fail("expected %s, got %s", strings.Join(schema.Type, " or "), actual)
+
+			return mismatches
+		}
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema, value) {
+		fail("%s is not one of the allowed values", compactJSON(value))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %s", name)
+			}
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			child := pointer + "/" + pointerEscaper.Replace(key)
+
+			if schema.Properties != nil {
+				if prop, ok := schema.Properties.Get(key); ok {
+					mismatches = append(mismatches, validateValue(prop, v[key], child)...)
+					continue
+				}
+			}
+
+			switch extra := schema.AdditionalProperties; {
+			case extra == nil:
+			case extra.IsB() && !extra.B:
+				mismatches = append(mismatches, Mismatch{Pointer: child, Message: "unexpected property"})
+			case extra.IsA():
+				mismatches = append(mismatches, validateValue(extra.A, v[key], child)...)
+			}
+		}
+	case []any:
+		if schema.MinItems != nil && int64(len(v)) < *schema.MinItems {
+			fail("has %d items, expected at least %d", len(v), *schema.MinItems)
+		}
+		if schema.MaxItems != nil && int64(len(v)) > *schema.MaxItems {
+			fail("has %d items, expected at most %d", len(v), *schema.MaxItems)
+		}
+
+		if schema.Items != nil && schema.Items.IsA() {
+			for i, item := range v {
+				mismatches = append(mismatches, validateValue(schema.Items.A, item, pointer+"/"+strconv.Itoa(i))...)
+			}
+		}
+	case string:
+		length := int64(utf8.RuneCountInString(v))
+		if schema.MinLength != nil && length < *schema.MinLength {
+			fail("has length %d, expected at least %d", length, *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			fail("has length %d, expected at most %d", length, *schema.MaxLength)
+		}
+
+		if schema.Pattern != "" {
+			if re, err := regexp.Compile(schema.Pattern); err == nil && !re.MatchString(v) {
+				fail("does not match the pattern %s", schema.Pattern)
+			}
+		}
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			break
+		}
+
+		if schema.Minimum != nil && n < *schema.Minimum {
+			fail("%s is less than the minimum %v", v, *schema.Minimum)
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			fail("%s is more than the maximum %v", v, *schema.Maximum)
+		}
+
+		// A bound of its own since 3.1, a flag on the minimum and maximum before
+		if exclusive := schema.ExclusiveMinimum; exclusive != nil {
+			if bound, ok := exclusiveBound(exclusive, schema.Minimum); ok && n <= bound {
+				fail("%s is not more than the exclusive minimum %v", v, bound)
+			}
+		}
+		if exclusive := schema.ExclusiveMaximum; exclusive != nil {
+			if bound, ok := exclusiveBound(exclusive, schema.Maximum); ok && n >= bound {
+				fail("%s is not less than the exclusive maximum %v", v, bound)
+			}
+		}
+	}
+
+	return mismatches
+}
+
+func exclusiveBound(exclusive *base.DynamicValue[bool, float64], inclusive *float64) (float64, bool) {
+	if exclusive.IsB() {
+		return exclusive.B, true
+	}
+
+	if exclusive.A && inclusive != nil {
+		return *inclusive, true
+	}
+
+	return 0, false
+}
+
+func countMatches(schemas []*base.SchemaProxy, value any) int {
+	n := 0
+	for _, schema := range schemas {
+		if len(validateValue(schema, value, "")) == 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+func inEnum(schema *base.Schema, value any) bool {
+	encoded := compactJSON(value)
+	for _, node := range schema.Enum {
+		allowed, err := fromNode(node)
+		if err == nil && compactJSON(allowed) == encoded {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The JSON Schema type of a decoded JSON value
+func jsonTypeOf(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if n, err := v.Float64(); err == nil && n == math.Trunc(n) {
+			return "integer"
+		}
+
+		return "number"
+	case nil:
+		return "null"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
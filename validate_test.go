@@ -0,0 +1,182 @@
+package climate
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const validateSpec = `
+openapi: "3.1.0"
+info:
+  title: Validate
+  version: "0.1.0"
+paths:
+  "/items":
+    get:
+      operationId: ListItems
+      responses:
+        "200":
+          description: The items
+          content:
+            application/json:
+              schema:
+                type: array
+                maxItems: 3
+                items:
+                  $ref: "#/components/schemas/Item"
+        "204":
+          description: No items
+        4XX:
+          description: A client error
+          content:
+            application/problem+json:
+              schema:
+                type: object
+                required: [title]
+                properties:
+                  title:
+                    type: string
+        default:
+          description: Anything else
+          content:
+            text/*:
+              schema:
+                type: string
+components:
+  schemas:
+    Item:
+      type: object
+      required: [name, size]
+      additionalProperties: false
+      properties:
+        name:
+          type: string
+          minLength: 1
+          pattern: "^[a-z/~]+$"
+        size:
+          type: integer
+          minimum: 0
+          exclusiveMaximum: 100
+        kind:
+          enum: [file, dir]
+        owner:
+          type: [string, "null"]
+        meta:
+          type: object
+          additionalProperties:
+            type: boolean
+`
+
+func TestParseValidationMode(t *testing.T) {
+	mode, err := parseValidationMode("Strict")
+	assert.NoError(t, err)
+	assert.Equal(t, ValidateStrict, mode)
+
+	_, err = parseValidationMode("loud")
+	assert.ErrorContains(t, err, "Unknown validation mode loud, choose one of: off, warn, strict")
+}
+
+func TestValidateResponse(t *testing.T) {
+	model, err := LoadV3([]byte(validateSpec))
+	assert.NoError(t, err)
+
+	op := model.Model.Paths.PathItems.GetOrZero("/items").Get
+	validate := func(status int, contentType, body string) []string {
+		var found []string
+		for _, m := range validateResponse(op.Responses, status, contentType, []byte(body)) {
+			found = append(found, m.String())
+		}
+
+		return found
+	}
+
+	assert.Empty(t, validate(200, "application/json", `[{"name": "a/b", "size": 1, "kind": "dir", "owner": null}]`))
+	assert.Empty(t, validate(200, "application/json", `[{"name": "a", "size": 2.0, "meta": {"x": true}}]`))
+	assert.Empty(t, validate(204, "", ""))
+	assert.Empty(t, validate(404, "application/problem+json", `{"title": "Not Found"}`))
+	assert.Empty(t, validate(500, "text/plain", "oops"))
+
+	assert.Equal(
+		t,
+		[]string{
+			"has 4 items, expected at most 3",
+			"/0: missing required property size",
+			"/0/name: does not match the pattern ^[a-z/~]+$",
+			"/1/kind: \"link\" is not one of the allowed values",
+			"/1/meta/x: expected boolean, got string",
+			"/1/size: 100 is not less than the exclusive maximum 100",
+			"/2/a~1b~0c: unexpected property",
+			"/2/name: has length 0, expected at least 1",
+			"/2/name: does not match the pattern ^[a-z/~]+$",
+			"/2/size: expected integer, got number",
+		},
+		validate(200, "application/json", `[
+			{"name": "A"},
+			{"name": "b", "size": 100, "kind": "link", "meta": {"x": "yes"}},
+			{"name": "", "size": 1.5, "a/b~c": 1},
+			{"name": "d", "size": 0}
+		]`),
+	)
+	assert.Equal(t, []string{"expected array, got object"}, validate(200, "application/json", `{}`))
+	assert.Equal(t, []string{"missing required property title"}, validate(400, "application/problem+json", `{}`))
+	assert.Equal(
+		t,
+		[]string{`Unexpected content type "text/html", expected one of: application/json`},
+		validate(200, "text/html", "<p>"),
+	)
+	assert.Equal(t, []string{"Unexpected body for status 204, the spec declares none"}, validate(204, "text/plain", "hi"))
+	assert.Contains(t, validate(200, "application/json", "[")[0], "Invalid JSON body")
+}
+
+func TestValidateResponseModes(t *testing.T) {
+	model, err := LoadV3([]byte(validateSpec))
+	assert.NoError(t, err)
+
+	op := model.Model.Paths.PathItems.GetOrZero("/items").Get
+	response := func() *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`[{"name": "a"}]`)),
+		}
+	}
+
+	data := HandlerData{Output: JSON, responses: op.Responses}
+	var out bytes.Buffer
+	assert.NoError(t, data.Render(&out, response()))
+	assert.Equal(t, `[{"name":"a"}]`+"\n", out.String())
+
+	data.Validation = ValidateWarn
+	out.Reset()
+	assert.NoError(t, data.Render(&out, response()))
+	assert.Equal(t, `[{"name":"a"}]`+"\n", out.String())
+
+	data.Validation = ValidateStrict
+	err = data.Render(io.Discard, response())
+	var validationErr *ResponseValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, 200, validationErr.Status)
+	assert.Equal(t, []Mismatch{{Pointer: "/0", Message: "missing required property size"}}, validationErr.Mismatches)
+	assert.EqualError(t, err, "Response with status 200 does not match the spec:\n  /0: missing required property size")
+
+	// The body can still be read after validating
+	resp := response()
+	assert.Error(t, data.ValidateResponse(resp))
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"name": "a"}]`, string(body))
+
+	assert.NoError(t, HandlerData{Validation: ValidateStrict}.ValidateResponse(response()))
+
+	// An undeclared failure is still an HTTP error, with the exit code of its status
+	failure := &http.Response{StatusCode: 500, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+	err = data.Render(io.Discard, failure)
+	var httpErr *HTTPError
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, 3, ExitCode(ExitCodes{ServerError: 3}.wrap(err)))
+}
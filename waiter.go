@@ -0,0 +1,363 @@
+// Copyright 2025 Rahul De
+// SPDX-License-Identifier: MIT
+
+// climate allows the server to influence the CLI behaviour by using OpenAPI's extensions.
+// It encourages spec-first practices thereby keeping both users and maintenance manageable.
+// It does just enough to handle the spec and nothing more.
+
+package climate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pb33f/jsonpath/pkg/jsonpath"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+)
+
+const (
+	waitCmd       = "wait"
+	waitUsage     = "Wait for a resource to reach a state"
+	waitFlag      = "wait"
+	waitFlagUsage = "Wait for %s after the operation"
+)
+
+// Defaults of the waiters
+const (
+	waitInterval      = 2 * time.Second
+	waitMaxInterval   = 30 * time.Second
+	waitTimeout       = 10 * time.Minute
+	waitResponseLimit = 1 << 20 // the bytes of the response of an operation kept at most to take params from
+)
+
+// Polls an operation until the state at a path is a success or failure one, set via x-cli-waiter
+type Waiter struct {
+	Name        string   `yaml:"name"`         // of the wait subcommand
+	Operation   string   `yaml:"operation"`    // the operationId of the operation to poll
+	Path        string   `yaml:"path"`         // a JSONPath to the state in its response, eg $.status
+	Success     []string `yaml:"success"`      // the states to stop at
+	Failure     []string `yaml:"failure"`      // the states to fail at
+	Interval    string   `yaml:"interval"`     // the delay before polling again, doubled each time. 2s by default
+	MaxInterval string   `yaml:"max-interval"` // the longest delay, 30s by default
+	Timeout     string   `yaml:"timeout"`      // how long to wait at most, 10m by default
+
+	// The params of the operation to poll taken from the response of this one as JSONPaths, eg id: $.id.
+	// The others are set from the flags of the same name.
+	Params map[string]string `yaml:"params"`
+
+	interval    time.Duration
+	maxInterval time.Duration
+	timeout     time.Duration
+	method      string
+	path        string
+	target      *v3.Operation
+	columns     []Column
+}
+
+// The error of a waiter reaching a failure state
+type WaitError struct {
+	Waiter string
+	State  string
+}
+
+func (e *WaitError) Error() string {
+	return fmt.Sprintf("Waiting for %s failed, the state is %s", e.Waiter, e.State)
+}
+
+// Validates the waiter of an operation, finding the one it polls
+func makeWaiter(model *v3.Document, op *v3.Operation, exts *extensions) (*Waiter, error) {
+	w := exts.waiter
+	if w == nil {
+		return nil, nil
+	}
+
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("Invalid x-cli-waiter in %s: %s", op.OperationId, fmt.Sprintf(format, args...))
+	}
+
+	if w.Name == "" || w.Operation == "" || w.Path == "" || len(w.Success) == 0 {
+		return nil, invalid("name, operation, path and success are needed")
+	}
+
+	if _, err := jsonpath.NewPath(w.Path); err != nil {
+		return nil, invalid("%s: %s", w.Path, err)
+	}
+
+	for _, d := range []struct {
+		value    string
+		duration *time.Duration
+		fallback time.Duration
+	}{
+		{w.Interval, &w.interval, waitInterval},
+		{w.MaxInterval, &w.maxInterval, waitMaxInterval},
+		{w.Timeout, &w.timeout, waitTimeout},
+	} {
+		*d.duration = d.fallback
+		if d.value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration <= 0 {
+			return nil, invalid("%s is not a positive duration", d.value)
+		}
+		*d.duration = duration
+	}
+
+	for path, item := range model.Paths.PathItems.FromOldest() {
+		for method, candidate := range item.GetOperations().FromOldest() {
+			if candidate.OperationId == w.Operation {
+				w.method, w.path, w.target = method, path, candidate
+			}
+		}
+	}
+
+	if w.target == nil {
+		return nil, invalid("unknown operation %s", w.Operation)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(w.Params)) {
+		if !slices.ContainsFunc(w.target.Parameters, func(p *v3.Parameter) bool { return p.Name == name }) {
+			return nil, invalid("%s has no param %s", w.Operation, name)
+		}
+
+		if _, err := jsonpath.NewPath(w.Params[name]); err != nil {
+			return nil, invalid("%s: %s", w.Params[name], err)
+		}
+	}
+
+	targetExts, err := parseExtensions(w.target.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.columns, err = makeColumns(w.target, targetExts); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// The data to poll the target with after running the operation of data.
+// Its params are taken from the response of the operation as set in Params, or from the flags of the same name.
+func (w *Waiter) targetData(ctx context.Context, data HandlerData, resolver credentialResolver) (HandlerData, error) {
+	target := data
+	target.Method, target.Path, target.Columns, target.responses = w.method, w.path, w.columns, w.target.Responses
+	target.PathParams, target.QueryParams, target.HeaderParams, target.CookieParams = paramMetas(w.target)
+	target.RequestBodyParam, target.Pagination, target.response = nil, nil, nil
+
+	mapped, err := w.mappedParams(data.response)
+	if err != nil {
+		return target, err
+	}
+	target.param = func(name string) (string, bool) {
+		if value, ok := mapped[name]; ok {
+			return value, true
+		}
+
+		if data.param == nil {
+			return "", false
+		}
+
+		return data.param(name)
+	}
+
+	for _, param := range target.PathParams {
+		value, ok := target.param(param.Name)
+		if !ok && w.Params[param.Name] != "" {
+			return target, fmt.Errorf("Cannot wait for %s, %s isn't in the response", w.Name, w.Params[param.Name])
+		}
+		if !ok {
+			return target, fmt.Errorf("Cannot wait for %s without --%s", w.Name, param.Name)
+		}
+
+		target.Path = strings.ReplaceAll(target.Path, "{"+param.Name+"}", url.PathEscape(value))
+	}
+
+	creds, err := resolver.resolve(ctx, w.target)
+	if err != nil {
+		return target, err
+	}
+	target.Credentials = creds
+
+	return target, nil
+}
+
+// The values of the params taken from the response of the operation, none of them without one
+func (w *Waiter) mappedParams(response *operationResponse) (map[string]string, error) {
+	mapped := make(map[string]string)
+
+	body := response.get()
+	if len(w.Params) == 0 || len(body) == 0 {
+		return mapped, nil
+	}
+
+	var value any
+	if err := decodeJSON(body, &value); err != nil {
+		return nil, fmt.Errorf("Cannot take the params of %s from the response: %w", w.Name, err)
+	}
+
+	for name, path := range w.Params {
+		selected, err := applyQuery(value, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if selected != nil {
+			mapped[name] = scalarString(selected)
+		}
+	}
+
+	return mapped, nil
+}
+
+// Waits after the operation of data, see targetData
+func (w *Waiter) waitAfter(ctx context.Context, out io.Writer, data HandlerData, resolver credentialResolver) error {
+	target, err := w.targetData(ctx, data, resolver)
+	if err != nil {
+		return err
+	}
+
+	return w.wait(ctx, out, target)
+}
+
+// Polls the target until a success state, rendering its last response, or a failure state
+func (w *Waiter) wait(ctx context.Context, out io.Writer, data HandlerData) error {
+	expired := errors.New("The waiter timed out")
+	ctx, cancel := context.WithTimeoutCause(ctx, w.timeout, expired)
+	defer cancel()
+
+	// The timeout of the waiter, or eg the --timeout of the command or Ctrl-C
+	delay, state := w.interval, ""
+	ended := func() error {
+		if cause := context.Cause(ctx); cause != expired {
+			return cause
+		}
+
+		if state == "" {
+			return fmt.Errorf("Timed out waiting for %s after %s", w.Name, w.timeout)
+		}
+
+		return fmt.Errorf("Timed out waiting for %s after %s, the state is %s", w.Name, w.timeout, state)
+	}
+
+	for {
+		value, err := w.poll(ctx, data)
+		if err != nil && ctx.Err() != nil {
+			return ended()
+		}
+		if err != nil {
+			return err
+		}
+
+		if state, err = w.state(value); err != nil {
+			return err
+		}
+
+		switch {
+		case slices.Contains(w.Success, state):
+			return renderer{format: data.Output, tty: isTerminal(out), columns: data.Columns, query: data.Query}.render(out, value)
+		case slices.Contains(w.Failure, state):
+			return &WaitError{Waiter: w.Name, State: state}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ended()
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, w.maxInterval)
+	}
+}
+
+func (w *Waiter) poll(ctx context.Context, data HandlerData) (any, error) {
+	req, err := data.NewRequest(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := data.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := data.ValidateResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, newHTTPError(resp, data.responses)
+	}
+
+	_, value, err := decodeResponse(resp)
+
+	return value, err
+}
+
+// The state at the path as a string, empty if not found
+func (w *Waiter) state(value any) (string, error) {
+	state, err := applyQuery(value, w.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if state == nil {
+		return "", nil
+	}
+
+	return scalarString(state), nil
+}
+
+// A decoded JSON value as a string: strings and numbers as they are, the others as JSON
+func scalarString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+
+	return compactJSON(value)
+}
+
+// The body of the last successful response of an operation, kept for its waiter to take params from
+type operationResponse struct {
+	mu   sync.Mutex
+	body []byte
+}
+
+// A body keeping what's read of it, unless it's too big
+func (o *operationResponse) capture(body io.ReadCloser) io.ReadCloser {
+	return &recordingBody{ReadCloser: body, limit: waitResponseLimit, done: func(content []byte, size int) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		o.body = nil
+		if size <= len(content) {
+			o.body = bytes.Clone(content)
+		}
+	}}
+}
+
+func (o *operationResponse) get() []byte {
+	if o == nil {
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.body
+}
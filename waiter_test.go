@@ -0,0 +1,250 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const waiterSpec = `
+openapi: "3.0.0"
+info:
+  title: Clusters
+  version: "0.1.0"
+servers:
+  - url: %s
+paths:
+  "/clusters":
+    post:
+      operationId: CreateCluster
+      x-cli-waiter:
+        name: cluster-ready
+        operation: GetCluster
+        path: $.status
+        success: [READY]
+        failure: [FAILED]
+        interval: 5ms
+        max-interval: 10ms
+        timeout: 5s
+      parameters:
+        - name: name
+          required: true
+          in: query
+          schema:
+            type: string
+      responses:
+        "201":
+          description: Created
+  "/clusters/generated":
+    post:
+      operationId: GenerateCluster
+      x-cli-waiter:
+        name: generated-ready
+        operation: GetCluster
+        path: $.status
+        success: [READY]
+        interval: 5ms
+        max-interval: 10ms
+        params:
+          name: $.name
+      responses:
+        "201":
+          description: Created
+  "/clusters/{name}":
+    get:
+      operationId: GetCluster
+      parameters:
+        - name: name
+          required: true
+          in: path
+          schema:
+            type: string
+      responses:
+        "200":
+          description: A cluster
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  name:
+                    type: string
+                  status:
+                    type: string
+`
+
+// Clusters named ok are ready on the third poll, bad ones fail on the second and slow ones never get ready.
+// Generated clusters are named ok.
+func clustersServer(t *testing.T) *httptest.Server {
+	var (
+		mu    sync.Mutex
+		polls = map[string]int{}
+	)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method == http.MethodPost {
+			name := r.URL.Query().Get("name")
+			if r.URL.Path == "/clusters/generated" {
+				name = "ok"
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, compactJSON(map[string]any{"name": name, "status": "PROVISIONING"}))
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/clusters/")
+		polls[name]++
+
+		status := "PROVISIONING"
+		switch {
+		case name == "ok" && polls[name] >= 3:
+			status = "READY"
+		case name == "bad" && polls[name] >= 2:
+			status = "FAILED"
+		case name == "missing":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"No such cluster"}`)
+			return
+		}
+
+		fmt.Fprint(w, compactJSON(map[string]any{"name": name, "status": status}))
+	}))
+}
+
+func TestWaiter(t *testing.T) {
+	server := clustersServer(t)
+	defer server.Close()
+
+	model, err := LoadV3(fmt.Appendf(nil, waiterSpec, server.URL))
+	assert.NoError(t, err)
+
+	op := model.Model.Paths.PathItems.GetOrZero("/clusters").Post
+	exts, err := parseExtensions(op.Extensions)
+	assert.NoError(t, err)
+
+	waiter, err := makeWaiter(&model.Model, op, exts)
+	assert.NoError(t, err)
+	assert.Equal(t, "get", waiter.method)
+	assert.Equal(t, "/clusters/{name}", waiter.path)
+	assert.Equal(t, 5*time.Millisecond, waiter.interval)
+	assert.Equal(t, 10*time.Millisecond, waiter.maxInterval)
+	assert.Equal(t, []Column{{Header: "name", Path: "$.name"}, {Header: "status", Path: "$.status"}}, waiter.columns)
+
+	wait := func(name string) (string, error) {
+		var out bytes.Buffer
+		data := HandlerData{Method: "get", Server: server.URL, Path: "/clusters/" + name, Output: JSON}
+		err := waiter.wait(context.Background(), &out, data)
+
+		return out.String(), err
+	}
+
+	out, err := wait("ok")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"ok","status":"READY"}`+"\n", out)
+
+	_, err = wait("bad")
+	assert.Equal(t, &WaitError{Waiter: "cluster-ready", State: "FAILED"}, err)
+	assert.EqualError(t, err, "Waiting for cluster-ready failed, the state is FAILED")
+
+	_, err = wait("missing")
+	assert.EqualError(t, err, "HTTP 404 Not Found: {\"message\":\"No such cluster\"}")
+
+	waiter.timeout = 20 * time.Millisecond
+	_, err = wait("slow")
+	assert.EqualError(t, err, "Timed out waiting for cluster-ready after 20ms, the state is PROVISIONING")
+
+	// The --timeout of the command is told apart from the one of the waiter
+	waiter.timeout = time.Minute
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 20*time.Millisecond, &TimeoutError{Timeout: 20 * time.Millisecond})
+	defer cancel()
+	err = waiter.wait(ctx, io.Discard, HandlerData{Method: "get", Server: server.URL, Path: "/clusters/slow", Output: JSON})
+	assert.EqualError(t, err, "Timed out after 20ms")
+	assert.Equal(t, DefaultExitCodes.Timeout, ExitCode(DefaultExitCodes.wrap(err)))
+
+	// The params of the target are the flags of the same name of the operation
+	data := HandlerData{Method: "post", Path: "/clusters", param: func(string) (string, bool) { return "a b", true }}
+	target, err := waiter.targetData(context.Background(), data, credentialResolver{model: &model.Model})
+	assert.NoError(t, err)
+	assert.Equal(t, "get", target.Method)
+	assert.Equal(t, "/clusters/a%20b", target.Path)
+
+	data.param = func(string) (string, bool) { return "", false }
+	_, err = waiter.targetData(context.Background(), data, credentialResolver{model: &model.Model})
+	assert.EqualError(t, err, "Cannot wait for cluster-ready without --name")
+
+	// Or taken from the response of the operation
+	generate := model.Model.Paths.PathItems.GetOrZero("/clusters/generated").Post
+	exts, err = parseExtensions(generate.Extensions)
+	assert.NoError(t, err)
+	waiter, err = makeWaiter(&model.Model, generate, exts)
+	assert.NoError(t, err)
+
+	data.response = &operationResponse{body: []byte(`{"name":"c 1"}`)}
+	target, err = waiter.targetData(context.Background(), data, credentialResolver{model: &model.Model})
+	assert.NoError(t, err)
+	assert.Equal(t, "/clusters/c%201", target.Path)
+	assert.Nil(t, target.response)
+
+	data.response = &operationResponse{body: []byte(`{"id":"c1"}`)}
+	_, err = waiter.targetData(context.Background(), data, credentialResolver{model: &model.Model})
+	assert.EqualError(t, err, "Cannot wait for generated-ready, $.name isn't in the response")
+}
+
+func TestMakeWaiter(t *testing.T) {
+	model, err := LoadV3(fmt.Appendf(nil, waiterSpec, "http://localhost"))
+	assert.NoError(t, err)
+	op := model.Model.Paths.PathItems.GetOrZero("/clusters").Post
+
+	invalid := func(w Waiter) error {
+		_, err := makeWaiter(&model.Model, op, &extensions{waiter: &w})
+		return err
+	}
+
+	assert.EqualError(t, invalid(Waiter{Name: "ready"}), "Invalid x-cli-waiter in CreateCluster: name, operation, path and success are needed")
+	assert.ErrorContains(t, invalid(Waiter{Name: "ready", Operation: "GetCluster", Path: "status", Success: []string{"READY"}}), "Invalid x-cli-waiter in CreateCluster: status")
+	assert.EqualError(
+		t,
+		invalid(Waiter{Name: "ready", Operation: "GetCluster", Path: "$.status", Success: []string{"READY"}, Interval: "-1s"}),
+		"Invalid x-cli-waiter in CreateCluster: -1s is not a positive duration",
+	)
+	assert.EqualError(
+		t,
+		invalid(Waiter{Name: "ready", Operation: "ListClusters", Path: "$.status", Success: []string{"READY"}}),
+		"Invalid x-cli-waiter in CreateCluster: unknown operation ListClusters",
+	)
+	assert.EqualError(
+		t,
+		invalid(Waiter{Name: "ready", Operation: "GetCluster", Path: "$.status", Success: []string{"READY"}, Params: map[string]string{"id": "$.id"}}),
+		"Invalid x-cli-waiter in CreateCluster: GetCluster has no param id",
+	)
+	assert.ErrorContains(
+		t,
+		invalid(Waiter{Name: "ready", Operation: "GetCluster", Path: "$.status", Success: []string{"READY"}, Params: map[string]string{"name": "name"}}),
+		"Invalid x-cli-waiter in CreateCluster: name",
+	)
+
+	w, err := makeWaiter(&model.Model, op, &extensions{waiter: &Waiter{Name: "ready", Operation: "GetCluster", Path: "$.status", Success: []string{"READY"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, waitInterval, w.interval)
+	assert.Equal(t, waitMaxInterval, w.maxInterval)
+	assert.Equal(t, waitTimeout, w.timeout)
+
+	w, err = makeWaiter(&model.Model, op, &extensions{})
+	assert.NoError(t, err)
+	assert.Nil(t, w)
+}